@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"devscope/internal/indexer"
@@ -22,6 +25,10 @@ func main() {
 		runIndex(os.Args[2:])
 	case "search":
 		runSearch(os.Args[2:])
+	case "tail":
+		runTail(os.Args[2:])
+	case "gc":
+		runGC(os.Args[2:])
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
@@ -29,9 +36,69 @@ func main() {
 	}
 }
 
+const indexUsage = "Usage: devscope index [--force] [--workers N] [--max-file-size BYTES] [--include GLOB] [--exclude GLOB] <path_to_index>"
+
 func runIndex(args []string) {
 	if len(args) < 1 {
-		fmt.Println("Usage: devscope index <path_to_index>")
+		fmt.Println(indexUsage)
+		os.Exit(1)
+	}
+
+	force := false
+	workers := 0
+	var maxFileSize int64
+	var include, exclude []string
+
+	parsingFlags := true
+	for parsingFlags && len(args) > 0 {
+		switch args[0] {
+		case "--force":
+			force = true
+			args = args[1:]
+		case "--workers":
+			if len(args) < 2 {
+				fmt.Println("--workers requires a value")
+				os.Exit(1)
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				fmt.Printf("--workers: %v\n", err)
+				os.Exit(1)
+			}
+			workers = n
+			args = args[2:]
+		case "--max-file-size":
+			if len(args) < 2 {
+				fmt.Println("--max-file-size requires a value")
+				os.Exit(1)
+			}
+			n, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				fmt.Printf("--max-file-size: %v\n", err)
+				os.Exit(1)
+			}
+			maxFileSize = n
+			args = args[2:]
+		case "--include":
+			if len(args) < 2 {
+				fmt.Println("--include requires a value")
+				os.Exit(1)
+			}
+			include = append(include, args[1])
+			args = args[2:]
+		case "--exclude":
+			if len(args) < 2 {
+				fmt.Println("--exclude requires a value")
+				os.Exit(1)
+			}
+			exclude = append(exclude, args[1])
+			args = args[2:]
+		default:
+			parsingFlags = false
+		}
+	}
+	if len(args) < 1 {
+		fmt.Println(indexUsage)
 		os.Exit(1)
 	}
 
@@ -45,7 +112,8 @@ func runIndex(args []string) {
 
 	fmt.Printf("Indexing %s -> %s\n", root, outDir)
 
-	builder := indexer.NewIndexBuilder(outDir)
+	builder := indexer.NewIndexBuilder(outDir, indexer.WithWorkers(workers), indexer.WithMaxFileSize(maxFileSize), indexer.WithGlobs(include, exclude))
+	builder.Force = force
 	if err := builder.Build(root); err != nil {
 		fmt.Printf("Indexing failed: %v\n", err)
 		os.Exit(1)
@@ -54,13 +122,80 @@ func runIndex(args []string) {
 	fmt.Println("Indexing complete.")
 }
 
+// runGC prunes cached token streams (see indexer.GC) that no longer belong
+// to any document in the current docs.bin - files deleted, renamed away
+// from, or edited since they were last indexed. This is purely a disk-space
+// reclamation step for the content-addressed object cache; postings for
+// deleted/renamed/edited files are already dropped by the next `devscope
+// index` run itself (see indexer.clearSegments), not by this command.
+func runGC(args []string) {
+	outDir := ".devscope"
+	removed, err := indexer.GC(outDir)
+	if err != nil {
+		fmt.Printf("gc failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed %d stale cache object(s).\n", removed)
+}
+
 func runSearch(args []string) {
 	if len(args) < 1 {
-		fmt.Println("Usage: devscope search <query>")
+		fmt.Println("Usage: devscope search [--regex] [--since T] [--until T] [--level LEVEL] <query>")
+		os.Exit(1)
+	}
+
+	useRegex := false
+	var since, until, level string
+
+	parsingFlags := true
+	for parsingFlags && len(args) > 0 {
+		switch args[0] {
+		case "--regex":
+			useRegex = true
+			args = args[1:]
+		case "--since":
+			if len(args) < 2 {
+				fmt.Println("--since requires a value")
+				os.Exit(1)
+			}
+			since, args = args[1], args[2:]
+		case "--until":
+			if len(args) < 2 {
+				fmt.Println("--until requires a value")
+				os.Exit(1)
+			}
+			until, args = args[1], args[2:]
+		case "--level":
+			if len(args) < 2 {
+				fmt.Println("--level requires a value")
+				os.Exit(1)
+			}
+			level, args = args[1], args[2:]
+		default:
+			parsingFlags = false
+		}
+	}
+	if len(args) < 1 {
+		fmt.Println("Usage: devscope search [--regex] [--since T] [--until T] [--level LEVEL] <query>")
 		os.Exit(1)
 	}
 
-	queryStr := strings.Join(args, " ")
+	// --since/--until/--level are sugar for the since:/until:/level:
+	// filter prefixes parseQuery already understands, so they're folded
+	// into the query string rather than threaded through as separate
+	// parameters.
+	var prefixes []string
+	if since != "" {
+		prefixes = append(prefixes, "since:"+since)
+	}
+	if until != "" {
+		prefixes = append(prefixes, "until:"+until)
+	}
+	if level != "" {
+		prefixes = append(prefixes, "level:"+level)
+	}
+
+	queryStr := strings.Join(append(prefixes, args...), " ")
 	outDir := ".devscope"
 
 	// open the index so we can search it
@@ -72,7 +207,12 @@ func runSearch(args []string) {
 	defer idxReader.Close()
 
 	start := time.Now()
-	results, err := query.Search(idxReader, queryStr)
+	var results []query.SearchResult
+	if useRegex {
+		results, err = query.RegexSearch(idxReader, queryStr)
+	} else {
+		results, err = query.Search(idxReader, queryStr, query.NewBM25Scorer())
+	}
 	if err != nil {
 		fmt.Printf("Search failed: %v\n", err)
 		os.Exit(1)
@@ -82,14 +222,58 @@ func runSearch(args []string) {
 
 	fmt.Printf("Found %d results in %v:\n", len(results), duration)
 	for i, res := range results {
-		fmt.Printf("%d. %s (Line: %d, Score: %.2f, Matches: %d)\n", i+1, res.Path, res.LineNum, res.Score, res.MatchCount)
+		lang := idxReader.Docs[res.DocID].Language
+		if lang != "" {
+			fmt.Printf("%d. %s [%s] (Line: %d, Score: %.2f, Matches: %d)\n", i+1, res.Path, lang, res.LineNum, res.Score, res.MatchCount)
+		} else {
+			fmt.Printf("%d. %s (Line: %d, Score: %.2f, Matches: %d)\n", i+1, res.Path, res.LineNum, res.Score, res.MatchCount)
+		}
 		fmt.Printf("   %s\n\n", res.Snippet)
 	}
 }
 
+// runTail keeps indexing newly appended lines in a directory's .log files
+// until interrupted, instead of waiting for the next full `devscope index`.
+func runTail(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: devscope tail <path_to_log_dir>")
+		os.Exit(1)
+	}
+
+	dir := args[0]
+	outDir := ".devscope"
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fmt.Printf("Failed to create output directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Tailing %s -> %s (Ctrl+C to stop)\n", dir, outDir)
+
+	tailer := indexer.NewLogTailer(dir, outDir)
+	stop := make(chan struct{})
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		close(stop)
+	}()
+
+	if err := tailer.Run(stop); err != nil {
+		fmt.Printf("Log tailing failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func printUsage() {
 	fmt.Println("DevScope - Code & Log Search Engine")
 	fmt.Println("Usage:")
-	fmt.Println("  devscope index <path>   # recursive index")
+	fmt.Println("  devscope index [--force] <path> # recursive index (--force bypasses the token cache)")
+	fmt.Println("  devscope index --workers N --max-file-size BYTES --include GLOB --exclude GLOB <path> # tune the parallel indexing pipeline")
+	fmt.Println("  devscope gc             # prune cached tokens no longer referenced by docs.bin")
 	fmt.Println("  devscope search <query> # search indexed data")
+	fmt.Println("  devscope search --regex <pattern> # regex/substring search via the trigram index")
+	fmt.Println("  devscope search path:<dir> / file:<name> <query> # scope a search to a path or filename")
+	fmt.Println("  devscope search --since T --until T --level LEVEL <query> # time-range/log-level filters")
+	fmt.Println("  devscope tail <dir>     # stream-index appended .log lines")
 }
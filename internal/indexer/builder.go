@@ -1,99 +1,503 @@
 package indexer
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"sort"
-	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"devscope/internal/store"
 	"devscope/pkg/models"
 )
 
-var reFileNameToken = regexp.MustCompile(`[a-zA-Z0-9_]+`)
-
 type IndexBuilder struct {
+	OutDir      string
 	DocsPath    string
-	IndexPath   string
-	LexiconPath string
+	SuffixPath  string
+	TrigramPath string
 
 	memIndex map[string]map[uint32]*models.Posting
+
+	// suffixBuf is the concatenation of every indexed document's raw bytes,
+	// and suffixDocOffsets maps runs of it back to (DocID, in-file offset)
+	// so query.SubstringSearch can resolve a match. See suffix.go.
+	suffixBuf        []byte
+	suffixDocOffsets []suffixDocOffset
+
+	// trigramIndex maps every distinct 3-byte substring seen across the
+	// corpus to the set of docs it appears in, written out as trigram.bin
+	// for query.RegexSearch to plan candidate docs from. See trigram.go.
+	trigramIndex map[string]map[uint32]struct{}
+
+	// SegmentFlushEvery bounds how many documents accumulate in memIndex
+	// before it's flushed out as a new immutable on-disk segment, instead
+	// of holding the whole corpus in RAM and writing one monolithic
+	// index.bin. See segments.go.
+	SegmentFlushEvery int
+
+	// Force bypasses the content-addressed token cache (see cache.go),
+	// making Build() re-tokenize every file regardless of whether its
+	// fingerprint or content hash matches a previous run. Fresh results
+	// are still written back to the cache, so a subsequent non-forced
+	// Build() benefits from it again.
+	Force bool
+
+	// Workers is how many tokenizer goroutines run concurrently between
+	// the walker and the writer stage in Build(). Defaults to
+	// runtime.NumCPU().
+	Workers int
+
+	// MaxFileSize skips (with a warning) any file larger than this many
+	// bytes instead of reading and tokenizing it. Zero means no limit.
+	MaxFileSize int64
+
+	// IncludeGlobs, if non-empty, restricts Build() to paths matching at
+	// least one pattern; ExcludeGlobs is checked first and always wins.
+	// Patterns are matched with path/filepath.Match against both the path
+	// relative to Build's root and the file's basename.
+	IncludeGlobs []string
+	ExcludeGlobs []string
+
+	// ProgressEvery controls how often Build prints "Indexed N files..." -
+	// every ProgressEvery files the writer stage completes.
+	ProgressEvery int
+}
+
+// Option configures an IndexBuilder constructed via NewIndexBuilder.
+type Option func(*IndexBuilder)
+
+// WithWorkers sets how many tokenizer goroutines Build() runs concurrently.
+// n <= 0 is ignored, leaving the runtime.NumCPU() default in place.
+func WithWorkers(n int) Option {
+	return func(b *IndexBuilder) {
+		if n > 0 {
+			b.Workers = n
+		}
+	}
+}
+
+// WithMaxFileSize skips any file larger than n bytes instead of tokenizing
+// it. n <= 0 means no limit.
+func WithMaxFileSize(n int64) Option {
+	return func(b *IndexBuilder) {
+		b.MaxFileSize = n
+	}
+}
+
+// WithGlobs restricts Build() to paths matching include (if non-empty) and
+// not matching exclude. See IncludeGlobs/ExcludeGlobs.
+func WithGlobs(include, exclude []string) Option {
+	return func(b *IndexBuilder) {
+		b.IncludeGlobs = include
+		b.ExcludeGlobs = exclude
+	}
+}
+
+func NewIndexBuilder(outDir string, opts ...Option) *IndexBuilder {
+	b := &IndexBuilder{
+		OutDir:            outDir,
+		DocsPath:          outDir + "/" + models.DocsFileName,
+		SuffixPath:        outDir + "/" + models.SuffixFileName,
+		TrigramPath:       outDir + "/" + models.TrigramFileName,
+		memIndex:          make(map[string]map[uint32]*models.Posting),
+		SegmentFlushEvery: defaultSegmentFlushEvery,
+		Workers:           runtime.NumCPU(),
+		ProgressEvery:     100,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
 }
 
-func NewIndexBuilder(outDir string) *IndexBuilder {
-	return &IndexBuilder{
-		DocsPath:    outDir + "/" + models.DocsFileName,
-		IndexPath:   outDir + "/" + models.IndexFileName,
-		LexiconPath: outDir + "/" + models.LexiconFileName,
-		memIndex:    make(map[string]map[uint32]*models.Posting),
+// matchesGlobs reports whether relPath should be indexed, per
+// ExcludeGlobs/IncludeGlobs.
+func (b *IndexBuilder) matchesGlobs(relPath string) bool {
+	base := filepath.Base(relPath)
+	for _, pat := range b.ExcludeGlobs {
+		if ok, _ := filepath.Match(pat, relPath); ok {
+			return false
+		}
+		if ok, _ := filepath.Match(pat, base); ok {
+			return false
+		}
+	}
+	if len(b.IncludeGlobs) == 0 {
+		return true
+	}
+	for _, pat := range b.IncludeGlobs {
+		if ok, _ := filepath.Match(pat, relPath); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pat, base); ok {
+			return true
+		}
 	}
+	return false
+}
+
+// crawled is one file the walker stage found, queued for a tokenizer
+// worker to stat, read, and hash/tokenize.
+type crawled struct {
+	Path string
+	Type models.DocType
+}
+
+// tokenized is one file's result from a tokenizer worker, ready for the
+// single writer goroutine to assign a DocID (if it doesn't already have
+// one reused from a previous run) and merge into the on-disk structures.
+type tokenized struct {
+	doc       models.DocumentRecord
+	content   []byte
+	tokens    []RawToken
+	fromCache bool
 }
 
 // this does everything: crawl, tokenize, save
+//
+// Incremental: if DocsPath already exists from a previous run, we load it
+// first and reuse the DocID for any file whose path already had one.
+// Token streams themselves are cached content-addressed by SHA-256 (see
+// cache.go) rather than by DocID, so reuse also kicks in across a rename
+// or a byte-identical duplicate placed elsewhere in the tree - not just an
+// unchanged path. Genuinely new files get a fresh DocID from the persisted
+// counter so IDs never get reused out from under a stale reference. Set
+// Force to skip all of this and re-tokenize everything unconditionally.
+//
+// Internally this runs as three pipeline stages joined by an
+// errgroup.Group: a single walker stage feeding paths into a buffered
+// channel, Workers tokenizer goroutines reading/hashing/tokenizing those
+// files concurrently, and a single writer goroutine that assigns DocIDs
+// and merges each result into docs.bin/memIndex/the suffix and trigram
+// buffers. Keeping DocID assignment and every in-memory structure owned by
+// that one writer goroutine means none of it needs a lock. If any stage
+// returns an error the errgroup cancels the shared context and Build
+// returns that error once every goroutine has unwound.
 func (b *IndexBuilder) Build(root string) error {
 	start := time.Now()
 
+	prevDocs, nextDocID := b.loadPrevious()
+
+	// Every file still present gets re-added to memIndex below (cache hits
+	// included, not just changed files), so the segments this run flushes
+	// already cover the complete current corpus on their own. Clear the
+	// previous run's segments first so a changed file's old postings don't
+	// linger alongside its new ones, and so a deleted/renamed-away file's
+	// postings actually disappear instead of waiting on compaction to
+	// happen to touch that segment. See clearSegments in segments.go.
+	if err := clearSegments(b.OutDir); err != nil {
+		return fmt.Errorf("failed to clear previous segments: %w", err)
+	}
+
 	docWriter, err := store.NewDocWriter(b.DocsPath)
 	if err != nil {
 		return fmt.Errorf("failed to open docs file: %w", err)
 	}
-	defer docWriter.Close()
 
-	crawler := NewCrawler(root)
-	docsChan := make(chan models.DocumentRecord)
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
 
-	go crawler.Crawl(docsChan)
+	g, ctx := errgroup.WithContext(context.Background())
+
+	pathsChan := make(chan crawled, workers*2)
+	resultsChan := make(chan tokenized, workers*2)
+
+	g.Go(func() error {
+		defer close(pathsChan)
+		return b.walk(ctx, root, pathsChan)
+	})
+
+	var workersWG sync.WaitGroup
+	workersWG.Add(workers)
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			defer workersWG.Done()
+			return b.tokenizeWorker(ctx, prevDocs, pathsChan, resultsChan)
+		})
+	}
+	g.Go(func() error {
+		workersWG.Wait()
+		close(resultsChan)
+		return nil
+	})
+
+	count, reused := 0, 0
+	g.Go(func() error {
+		for res := range resultsChan {
+			count++
+
+			doc := res.doc
+			if doc.DocID == 0 {
+				doc.DocID = nextDocID
+				nextDocID++
+			}
+			if res.fromCache {
+				reused++
+			}
+
+			if err := docWriter.Write(doc); err != nil {
+				return fmt.Errorf("failed to write doc: %w", err)
+			}
+
+			for _, tok := range res.tokens {
+				b.addToken(tok, doc.DocID)
+			}
+
+			b.addSuffixDoc(doc.DocID, res.content)
+			b.addTrigramDoc(doc.DocID, res.content)
+
+			// Index the path itself - full hierarchy, basename, and stem -
+			// so it's reachable by both path:/file: filters and plain term
+			// search. See pathindex.go.
+			b.addPathTerms(doc.Path, doc.DocID)
+
+			if count%b.ProgressEvery == 0 {
+				fmt.Printf("\rIndexed %d files...", count)
+			}
+
+			if count%b.SegmentFlushEvery == 0 {
+				if err := b.flushSegment(b.OutDir); err != nil {
+					return fmt.Errorf("failed to flush segment: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		docWriter.Close()
+		return err
+	}
+
+	// Stale cache objects (from files that were deleted, renamed away
+	// from, or edited) aren't pruned here - they just age out of
+	// relevance until `devscope gc` reclaims them against the set of
+	// ContentHashes this Build() just wrote to docs.bin.
+
+	if err := docWriter.CloseWithNextDocID(nextDocID); err != nil {
+		return fmt.Errorf("failed to close docs file: %w", err)
+	}
+
+	fmt.Printf("\nFinished indexing %d files (%d reused from cache) in %v. Flushing final segment...\n", count, reused, time.Since(start))
+
+	if err := b.flushSegment(b.OutDir); err != nil {
+		return fmt.Errorf("failed to flush final segment: %w", err)
+	}
+	if err := b.saveSuffix(); err != nil {
+		return fmt.Errorf("failed to save suffix index: %w", err)
+	}
+	return b.saveTrigrams()
+}
+
+// walk is the pipeline's walker stage: it crawls root and forwards every
+// file matching IncludeGlobs/ExcludeGlobs onto out, until the crawl
+// finishes or ctx is cancelled by another stage's error.
+func (b *IndexBuilder) walk(ctx context.Context, root string, out chan<- crawled) error {
+	crawler := NewCrawler(root)
+	rawChan := make(chan models.DocumentRecord)
 
-	count := 0
-	for doc := range docsChan {
-		count++
+	go crawler.Crawl(rawChan)
 
-		file, err := os.Open(doc.Path)
+	for rec := range rawChan {
+		rel, err := filepath.Rel(root, rec.Path)
 		if err != nil {
-			fmt.Printf("Warn: could not open %s: %v\n", doc.Path, err)
+			rel = rec.Path
+		}
+		if !b.matchesGlobs(rel) {
 			continue
 		}
 
-		tokens, minT, maxT := Tokenize(file, doc.Type)
-		file.Close()
+		select {
+		case out <- crawled{Path: rec.Path, Type: rec.Type}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// tokenizeWorker is one of the pipeline's tokenizer-stage goroutines: it
+// reads crawled files from in, stats/reads/hashes/tokenizes each one (the
+// CPU- and IO-heavy work Build used to do serially), and forwards the
+// result to out for the writer stage to merge in.
+func (b *IndexBuilder) tokenizeWorker(ctx context.Context, prevDocs map[string]models.DocumentRecord, in <-chan crawled, out chan<- tokenized) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case item, ok := <-in:
+			if !ok {
+				return nil
+			}
 
-		doc.TimestampMin = minT
-		doc.TimestampMax = maxT
+			res, skip := b.tokenizeOne(item, prevDocs)
+			if skip {
+				continue
+			}
 
-		if err := docWriter.Write(doc); err != nil {
-			return fmt.Errorf("failed to write doc: %w", err)
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
+	}
+}
+
+// tokenizeOne stats, reads, and (cache permitting) tokenizes a single
+// crawled file. The returned tokenized's DocID is already set to the
+// previous run's DocID if item.Path existed before - the writer stage only
+// needs to hand out a fresh one when it's still zero. skip is true for
+// files that couldn't be stat'd/read or that exceed MaxFileSize; those are
+// warned about here and otherwise dropped.
+func (b *IndexBuilder) tokenizeOne(item crawled, prevDocs map[string]models.DocumentRecord) (tokenized, bool) {
+	info, err := os.Stat(item.Path)
+	if err != nil {
+		fmt.Printf("Warn: could not stat %s: %v\n", item.Path, err)
+		return tokenized{}, true
+	}
+	if b.MaxFileSize > 0 && info.Size() > b.MaxFileSize {
+		fmt.Printf("Warn: skipping %s (%d bytes exceeds MaxFileSize)\n", item.Path, info.Size())
+		return tokenized{}, true
+	}
+
+	doc := models.DocumentRecord{
+		Type:  item.Type,
+		Path:  item.Path,
+		Size:  info.Size(),
+		Mtime: info.ModTime().UnixNano(),
+	}
+
+	// We always need the raw bytes for the suffix array, cache hit or not,
+	// so just read the whole file up front.
+	content, err := os.ReadFile(item.Path)
+	if err != nil {
+		fmt.Printf("Warn: could not read %s: %v\n", item.Path, err)
+		return tokenized{}, true
+	}
 
-		// put tokens in memory map for now
-		for _, tok := range tokens {
-			b.addToken(tok, doc.DocID)
+	prev, existed := prevDocs[item.Path]
+	if existed {
+		doc.DocID = prev.DocID
+	}
+
+	// Detected up front (not just for doc.Language) because Tokenize
+	// dispatches to a per-language tokenizer based on it, not on content
+	// alone - the cache key below has to account for that too, or two
+	// byte-identical files in different languages would wrongly share
+	// tokens tokenized for the wrong one.
+	var lang string
+	if doc.Type == models.DocTypeCode {
+		lang = DetectLanguage(doc.Path, content)
+	}
+	doc.Language = lang
+
+	var tokens []RawToken
+	var minT, maxT int64
+	fromCache := false
+
+	if !b.Force && existed && prev.Size == doc.Size && prev.Mtime == doc.Mtime {
+		// Fast path: stat matches, trust the previous content hash
+		// without re-hashing, and try to reuse its cached tokens.
+		doc.ContentHash = prev.ContentHash
+		if cached, cMin, cMax, ok := readObjectCache(b.OutDir, doc.ContentHash); ok {
+			tokens, minT, maxT = cached, cMin, cMax
+			fromCache = true
 		}
+	}
 
-		// ALSO we index the filename itself for the +5.0 bonus!
-		baseName := filepath.Base(doc.Path)
-		// remove extension for cleaner tokens? "main.cpp" -> "main", "cpp"
-		// simple regex find all works nicely
-		fnTokens := reFileNameToken.FindAllString(baseName, -1)
-		for _, term := range fnTokens {
-			// add with MetaInFileName, position 0 (header)
-			b.addToken(RawToken{
-				Term:     strings.ToLower(term),
-				Position: 0,
-				Meta:     MetaInFileName, // Same package constant
-			}, doc.DocID)
+	if !fromCache {
+		hash := contentHash(content, lang)
+		doc.ContentHash = hash
+
+		if !b.Force {
+			if cached, cMin, cMax, ok := readObjectCache(b.OutDir, hash); ok {
+				// Either mtime drifted (e.g. touch) with identical
+				// content, or this hash was cached under some other
+				// path entirely (a rename, or a duplicate elsewhere
+				// in the tree) - either way, no need to re-tokenize.
+				tokens, minT, maxT = cached, cMin, cMax
+				fromCache = true
+			}
 		}
 
-		if count%100 == 0 {
-			fmt.Printf("\rIndexed %d files...", count)
+		if !fromCache {
+			tokens, minT, maxT = Tokenize(bytes.NewReader(content), doc.Type, doc.Path)
+			if err := writeObjectCache(b.OutDir, hash, tokens, minT, maxT); err != nil {
+				fmt.Printf("Warn: could not cache tokens for %s (object %s): %v\n", doc.Path, shortHash(hash), err)
+			}
 		}
 	}
-	fmt.Printf("\nFinished core indexing of %d files in %v. Sorting and writing index...\n", count, time.Since(start))
 
-	return b.save()
+	doc.TimestampMin = minT
+	doc.TimestampMax = maxT
+	doc.TokenCount = uint32(len(tokens))
+
+	return tokenized{doc: doc, content: content, tokens: tokens, fromCache: fromCache}, false
+}
+
+// loadPrevious reads the docs.bin from the previous Build(), if any, keyed
+// by path so the new Build() can tell which files are unchanged. It also
+// returns the persisted NextDocID counter to continue from.
+func (b *IndexBuilder) loadPrevious() (map[string]models.DocumentRecord, uint32) {
+	prev := make(map[string]models.DocumentRecord)
+
+	reader, err := store.NewDocReader(b.DocsPath)
+	if err != nil {
+		// no previous index (or it's unreadable) - start fresh
+		return prev, 1
+	}
+	defer reader.Close()
+
+	for {
+		rec, err := reader.ReadNext()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Printf("Warn: could not fully read previous docs.bin: %v\n", err)
+			break
+		}
+		prev[rec.Path] = rec
+	}
+
+	nextDocID := reader.NextDocID
+	if nextDocID == 0 {
+		nextDocID = 1
+	}
+	return prev, nextDocID
+}
+
+// suffixDocDelimiter separates documents in the concatenated suffix buffer
+// so a match can never span the tail of one file and the head of the next
+// - the same reason index/suffixarray's own godoc recommends a NUL byte
+// between concatenated texts. It's written after each document's bytes but
+// excluded from that document's Length, so it belongs to no document;
+// SubstringSearch additionally rejects any match whose end crosses into a
+// document's successor, in case a pattern matches the delimiter itself.
+const suffixDocDelimiter = '\x00'
+
+// addSuffixDoc appends a document's raw bytes to the suffix buffer that
+// saveSuffix later builds a suffix array over.
+func (b *IndexBuilder) addSuffixDoc(docID uint32, content []byte) {
+	b.suffixDocOffsets = append(b.suffixDocOffsets, suffixDocOffset{
+		DocID:  docID,
+		Start:  int64(len(b.suffixBuf)),
+		Length: int64(len(content)),
+	})
+	b.suffixBuf = append(b.suffixBuf, content...)
+	b.suffixBuf = append(b.suffixBuf, suffixDocDelimiter)
 }
 
 func (b *IndexBuilder) addToken(tok RawToken, docID uint32) {
@@ -117,18 +521,93 @@ func (b *IndexBuilder) addToken(tok RawToken, docID uint32) {
 	post.Meta |= tok.Meta
 }
 
-// save everything to disk in binary format
-func (b *IndexBuilder) save() error {
-	idxFile, err := os.Create(b.IndexPath)
+const (
+	// idxVersionV2 is the delta+varint posting format (see encodePostingsV2).
+	// Readers must still accept idxVersionV1, the older fixed-width format,
+	// for segments written before this version existed; writeSegmentFiles
+	// only ever produces v2 segments, so a compaction upgrades any v1
+	// segment it touches to v2 for free. See query/segments.go for the
+	// mirrored reader-side constants and decode logic.
+	idxVersionV1 = 1
+	idxVersionV2 = 2
+
+	// skipInterval is how many postings separate each entry in a term's
+	// skip list: (absolute DocID, byte offset into the blob). It lets a
+	// reader jump near a target DocID - e.g. matchPhraseDocs narrowing a
+	// phrase's candidate set - without varint-decoding every posting before
+	// it.
+	skipInterval = 128
+)
+
+// skipEntry is one entry of a term's skip list: at least skipInterval
+// postings separate consecutive entries.
+type skipEntry struct {
+	docID  uint32
+	offset uint32 // byte offset of this posting within the blob
+}
+
+// encodePostingsV2 delta+varint-encodes a term's DocID-sorted postings
+// (DocID gaps, frequencies, and per-posting position gaps all as uvarints)
+// and returns the resulting blob alongside its skip list. This is what
+// shrinks posting lists 3-5x on real corpora over the old fixed 13
+// bytes/posting + 4 bytes/position layout.
+func encodePostingsV2(postings []*models.Posting) ([]byte, []skipEntry) {
+	var blob bytes.Buffer
+	var skip []skipEntry
+	varintBuf := make([]byte, binary.MaxVarintLen64)
+
+	prevDocID := uint32(0)
+	for i, p := range postings {
+		if i%skipInterval == 0 {
+			skip = append(skip, skipEntry{docID: p.DocID, offset: uint32(blob.Len())})
+		}
+
+		n := binary.PutUvarint(varintBuf, uint64(p.DocID-prevDocID))
+		blob.Write(varintBuf[:n])
+		prevDocID = p.DocID
+
+		n = binary.PutUvarint(varintBuf, uint64(p.Frequency))
+		blob.Write(varintBuf[:n])
+
+		blob.WriteByte(p.Meta)
+
+		// Positions aren't guaranteed sorted as collected (e.g. the
+		// filename-token bonus in Build tacks on Position 0 after whatever
+		// content positions were already recorded), so sort before delta
+		// encoding - matchPhraseDocs only cares about the set of
+		// positions, not their order.
+		positions := append([]uint32(nil), p.Positions...)
+		sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+
+		n = binary.PutUvarint(varintBuf, uint64(len(positions)))
+		blob.Write(varintBuf[:n])
+
+		prevPos := uint32(0)
+		for _, pos := range positions {
+			n = binary.PutUvarint(varintBuf, uint64(pos-prevPos))
+			blob.Write(varintBuf[:n])
+			prevPos = pos
+		}
+	}
+	return blob.Bytes(), skip
+}
+
+// writeSegmentFiles writes one SSTable-style segment: a v2 .idx file
+// holding the delta+varint-encoded postings (see encodePostingsV2) and a
+// .lex file holding the sorted term -> offset lexicon. Shared by
+// flushSegment (new segments from memIndex) and mergeSegments (compacted
+// segments from merged postings).
+func writeSegmentFiles(idxPath, lexPath string, memIndex map[string]map[uint32]*models.Posting) error {
+	idxFile, err := os.Create(idxPath)
 	if err != nil {
 		return err
 	}
 	defer idxFile.Close()
 
 	idxFile.WriteString("DEVSCOPE_IDX")
-	idxFile.Write([]byte{1})
+	idxFile.Write([]byte{idxVersionV2})
 
-	lexFile, err := os.Create(b.LexiconPath)
+	lexFile, err := os.Create(lexPath)
 	if err != nil {
 		return err
 	}
@@ -138,8 +617,8 @@ func (b *IndexBuilder) save() error {
 	lexFile.Write([]byte{1})
 
 	// sort terms so we can search faster later maybe?
-	terms := make([]string, 0, len(b.memIndex))
-	for t := range b.memIndex {
+	terms := make([]string, 0, len(memIndex))
+	for t := range memIndex {
 		terms = append(terms, t)
 	}
 	sort.Strings(terms)
@@ -148,9 +627,9 @@ func (b *IndexBuilder) save() error {
 	buf := make([]byte, 8)
 
 	for _, term := range terms {
-		docMap := b.memIndex[term]
+		docMap := memIndex[term]
 
-		// sort by docID for delta encoding later if we want
+		// sort by docID for delta encoding
 		postings := make([]*models.Posting, 0, len(docMap))
 		for _, p := range docMap {
 			postings = append(postings, p)
@@ -159,29 +638,26 @@ func (b *IndexBuilder) save() error {
 			return postings[i].DocID < postings[j].DocID
 		})
 
-		startOffset := indexOffset
-
-		// write each posting
-		for _, p := range postings {
-			binary.LittleEndian.PutUint32(buf, p.DocID)
-			idxFile.Write(buf[:4])
-
-			binary.LittleEndian.PutUint32(buf, p.Frequency)
-			idxFile.Write(buf[:4])
-
-			idxFile.Write([]byte{p.Meta})
+		blob, skip := encodePostingsV2(postings)
 
-			binary.LittleEndian.PutUint32(buf, uint32(len(p.Positions)))
-			idxFile.Write(buf[:4])
+		startOffset := indexOffset
 
-			for _, pos := range p.Positions {
-				binary.LittleEndian.PutUint32(buf, pos)
-				idxFile.Write(buf[:4])
-			}
+		binary.LittleEndian.PutUint32(buf, uint32(len(skip)))
+		idxFile.Write(buf[:4])
+		writtenLen := 4
 
-			indexOffset += uint64(13 + 4*len(p.Positions))
+		var skipEntryBuf [8]byte
+		for _, se := range skip {
+			binary.LittleEndian.PutUint32(skipEntryBuf[0:4], se.docID)
+			binary.LittleEndian.PutUint32(skipEntryBuf[4:8], se.offset)
+			idxFile.Write(skipEntryBuf[:])
+			writtenLen += 8
 		}
 
+		idxFile.Write(blob)
+		writtenLen += len(blob)
+
+		indexOffset += uint64(writtenLen)
 		postingListLen := indexOffset - startOffset
 
 		// write lexicon entry
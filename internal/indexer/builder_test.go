@@ -0,0 +1,65 @@
+package indexer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devscope/internal/indexer"
+	"devscope/internal/query"
+)
+
+// TestBuildDropsPostingsForDeletedFile covers the promise chunk0-1 made but
+// never actually implemented: a file that's deleted (or renamed away from)
+// before the next Build() must have its postings dropped, not just linger
+// in an old segment under a DocID that no longer appears in docs.bin.
+func TestBuildDropsPostingsForDeletedFile(t *testing.T) {
+	root := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), ".devscope")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc onlyHere() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := indexer.NewIndexBuilder(outDir).Build(root); err != nil {
+		t.Fatalf("initial Build: %v", err)
+	}
+
+	idx, err := query.NewIndexReader(outDir)
+	if err != nil {
+		t.Fatalf("opening index after initial build: %v", err)
+	}
+	results, err := query.Search(idx, "onlyHere", query.NewBM25Scorer())
+	if err != nil {
+		t.Fatalf("search after initial build: %v", err)
+	}
+	idx.Close()
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result before deletion, got %d", len(results))
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.NewIndexBuilder(outDir).Build(root); err != nil {
+		t.Fatalf("Build after deletion: %v", err)
+	}
+
+	idx, err = query.NewIndexReader(outDir)
+	if err != nil {
+		t.Fatalf("opening index after deletion: %v", err)
+	}
+	defer idx.Close()
+
+	results, err = query.Search(idx, "onlyHere", query.NewBM25Scorer())
+	if err != nil {
+		t.Fatalf("search after deletion: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected 0 results after deleting the only file containing the term, got %d: %+v", len(results), results)
+	}
+}
@@ -0,0 +1,201 @@
+package indexer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"devscope/internal/store"
+	"devscope/pkg/models"
+)
+
+// objectsDir is where per-file token streams are cached across Build()
+// runs, content-addressed by the SHA-256 hash of the file's bytes (see
+// contentHash) rather than by DocID. That means a file that moves, or a
+// duplicate placed elsewhere in the tree, reuses the same cached tokens as
+// long as its bytes are unchanged - a plain DocID-keyed cache would miss
+// both of those.
+const objectsDir = "objects"
+
+// contentHash returns the SHA-256 hash of content and language as a
+// lowercase hex string, the key under which a tokenized form is cached.
+// Tokenize dispatches to a per-language tokenizer based on
+// DetectLanguage(path, content), not on content alone, so two byte-
+// identical files detected as different languages (a duplicated stub, a
+// shared fixture, a generated file) must land on different cache keys -
+// otherwise whichever one is indexed second reuses the first's
+// Language-specific Meta bits and symbol-kind postings.
+func contentHash(content []byte, language string) string {
+	h := sha256.New()
+	h.Write(content)
+	h.Write([]byte{0})
+	h.Write([]byte(language))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// shortHash truncates a hex content hash to a 12-char display form (as in
+// Timecraft's Hash.Short), long enough to disambiguate in practice without
+// printing the full 64 chars in logs or error messages.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// objectPath mirrors git's object store layout: the hash's first two hex
+// chars name a bucket directory so the cache doesn't end up with one flat
+// directory holding one entry per distinct file in the corpus.
+func objectPath(outDir, hash string) string {
+	return filepath.Join(outDir, objectsDir, hash[:2], hash[2:]+".tok")
+}
+
+// writeObjectCache persists a file's token stream under its content hash.
+func writeObjectCache(outDir, hash string, tokens []RawToken, minT, maxT int64) error {
+	dir := filepath.Join(outDir, objectsDir, hash[:2])
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(objectPath(outDir, hash))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	if err := binary.Write(w, binary.LittleEndian, minT); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, maxT); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(tokens))); err != nil {
+		return err
+	}
+	for _, tok := range tokens {
+		termBytes := []byte(tok.Term)
+		if err := binary.Write(w, binary.LittleEndian, uint16(len(termBytes))); err != nil {
+			return err
+		}
+		if _, err := w.Write(termBytes); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, tok.Position); err != nil {
+			return err
+		}
+		if err := w.WriteByte(tok.Meta); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// readObjectCache loads a previously cached token stream for hash, if
+// present.
+func readObjectCache(outDir, hash string) ([]RawToken, int64, int64, bool) {
+	f, err := os.Open(objectPath(outDir, hash))
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var minT, maxT int64
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &minT); err != nil {
+		return nil, 0, 0, false
+	}
+	if err := binary.Read(r, binary.LittleEndian, &maxT); err != nil {
+		return nil, 0, 0, false
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, 0, 0, false
+	}
+
+	tokens := make([]RawToken, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var termLen uint16
+		if err := binary.Read(r, binary.LittleEndian, &termLen); err != nil {
+			return nil, 0, 0, false
+		}
+		termBytes := make([]byte, termLen)
+		if _, err := io.ReadFull(r, termBytes); err != nil {
+			return nil, 0, 0, false
+		}
+		var pos uint32
+		if err := binary.Read(r, binary.LittleEndian, &pos); err != nil {
+			return nil, 0, 0, false
+		}
+		meta, err := r.ReadByte()
+		if err != nil {
+			return nil, 0, 0, false
+		}
+		tokens = append(tokens, RawToken{Term: string(termBytes), Position: pos, Meta: meta})
+	}
+
+	return tokens, minT, maxT, true
+}
+
+// GC deletes every cached object under outDir/objects that isn't
+// referenced by any document in the current docs.bin, reclaiming cache
+// space from files that were deleted, renamed away from, or edited since
+// they were last indexed. It reports how many objects were removed.
+func GC(outDir string) (int, error) {
+	live := make(map[string]bool)
+
+	reader, err := store.NewDocReader(filepath.Join(outDir, models.DocsFileName))
+	if err == nil {
+		defer reader.Close()
+		for {
+			rec, err := reader.ReadNext()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				break
+			}
+			live[rec.ContentHash] = true
+		}
+	}
+
+	root := filepath.Join(outDir, objectsDir)
+	buckets, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	removed := 0
+	for _, bucket := range buckets {
+		if !bucket.IsDir() {
+			continue
+		}
+		bucketPath := filepath.Join(root, bucket.Name())
+		entries, err := os.ReadDir(bucketPath)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			hash := bucket.Name() + strings.TrimSuffix(entry.Name(), ".tok")
+			if live[hash] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(bucketPath, entry.Name())); err == nil {
+				removed++
+			}
+		}
+	}
+
+	return removed, nil
+}
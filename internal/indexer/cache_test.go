@@ -0,0 +1,63 @@
+package indexer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devscope/internal/indexer"
+	"devscope/internal/query"
+)
+
+// TestIdenticalBytesDifferentLanguagesDontShareCache covers chunk1-5: the
+// token cache was keyed only by a file's content hash, but Tokenize
+// dispatches to a per-language tokenizer based on DetectLanguage(path,
+// content), which also depends on the path/extension. Two byte-identical
+// files in different languages (a duplicated stub, a shared fixture) must
+// not reuse each other's Language-specific postings.
+func TestIdenticalBytesDifferentLanguagesDontShareCache(t *testing.T) {
+	root := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), ".devscope")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Valid Go (tokenizeGo's AST pass tags "handler" as MetaInFunctionName
+	// via the real FuncDecl), but tokenizePython's def/class regexes don't
+	// recognize Go's "func" syntax at all, so the same bytes tokenized as
+	// Python carry no symbol-kind bits for "handler".
+	content := []byte("package p\n\nfunc handler() {}\n")
+	if err := os.WriteFile(filepath.Join(root, "a", "dup.go"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b", "dup.py"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := indexer.NewIndexBuilder(outDir).Build(root); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	idx, err := query.NewIndexReader(outDir)
+	if err != nil {
+		t.Fatalf("opening index: %v", err)
+	}
+	defer idx.Close()
+
+	results, err := query.Search(idx, "kind:func handler", query.NewBM25Scorer())
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected kind:func handler to match only the Go file, got %d: %+v", len(results), results)
+	}
+	if results[0].Path != filepath.Join(root, "a", "dup.go") {
+		t.Fatalf("expected the match to be dup.go, got %s", results[0].Path)
+	}
+}
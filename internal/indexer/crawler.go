@@ -17,11 +17,13 @@ func NewCrawler(root string) *Crawler {
 }
 
 // this function walks thru all files recursively
+//
+// DocID is left zero here: the builder is the one that knows which paths
+// were already indexed, so it's the one that decides whether to reuse an
+// existing DocID or hand out a fresh one from the persisted counter.
 func (c *Crawler) Crawl(out chan<- models.DocumentRecord) error {
 	defer close(out)
 
-	docIDCounter := uint32(1)
-
 	// start walkin directory
 	return filepath.WalkDir(c.Root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -41,13 +43,11 @@ func (c *Crawler) Crawl(out chan<- models.DocumentRecord) error {
 		}
 
 		rec := models.DocumentRecord{
-			DocID: docIDCounter,
-			Type:  models.DocType(docType),
-			Path:  path,
+			Type: models.DocType(docType),
+			Path: path,
 		}
 
 		out <- rec
-		docIDCounter++
 		return nil
 	})
 }
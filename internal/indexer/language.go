@@ -0,0 +1,256 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+
+	enry "github.com/go-enry/go-enry/v2"
+)
+
+// DetectLanguage identifies a code file's language so Tokenize can pick a
+// tokenizer with richer symbol extraction than the generic identifier +
+// func/class-header regex fallback.
+func DetectLanguage(path string, content []byte) string {
+	return enry.GetLanguage(path, content)
+}
+
+// languageTokenizers maps a go-enry language name to a tokenizer with
+// symbol extraction tuned for that language. Anything not in this map
+// falls back to tokenizeCode.
+var languageTokenizers = map[string]func([]byte) []RawToken{
+	"Go":         tokenizeGo,
+	"Python":     tokenizePython,
+	"JavaScript": tokenizeJSLike,
+	"TypeScript": tokenizeJSLike,
+}
+
+// tokenizeGo tokenizes Go source the same way tokenizeCode does (every
+// identifier, one RawToken per occurrence, in source order) but upgrades
+// Meta using go/parser's AST instead of the generic reFuncDef regex: real
+// func/method/type declarations, import specs, and comments are all
+// resolved precisely instead of guessed at from a single line of text. If
+// the file doesn't parse (e.g. a snippet, or invalid Go), it falls back to
+// tokenizeCode, whose only symbol-kind bit is MetaInFunctionName from the
+// generic reFuncDef regex.
+func tokenizeGo(content []byte) []RawToken {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return tokenizeCode(bytes.NewReader(content))
+	}
+
+	funcNameByLine := make(map[int]string)
+	methodNameByLine := make(map[int]string)
+	typeNameByLine := make(map[int]string)
+	importLines := make(map[int]bool)
+	commentLines := make(map[int]bool)
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			line := fset.Position(d.Name.Pos()).Line
+			if d.Recv != nil {
+				methodNameByLine[line] = d.Name.Name
+			} else {
+				funcNameByLine[line] = d.Name.Name
+			}
+		case *ast.GenDecl:
+			switch d.Tok {
+			case token.TYPE:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						typeNameByLine[fset.Position(ts.Name.Pos()).Line] = ts.Name.Name
+					}
+				}
+			case token.IMPORT:
+				start := fset.Position(d.Pos()).Line
+				end := fset.Position(d.End()).Line
+				for l := start; l <= end; l++ {
+					importLines[l] = true
+				}
+			}
+		}
+	}
+
+	for _, cg := range file.Comments {
+		start := fset.Position(cg.Pos()).Line
+		end := fset.Position(cg.End()).Line
+		for l := start; l <= end; l++ {
+			commentLines[l] = true
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	var tokens []RawToken
+	tokenCounter := uint32(0)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+
+		for _, loc := range reIdentifier.FindAllStringIndex(text, -1) {
+			term := text[loc[0]:loc[1]]
+			meta := uint8(MetaNone)
+
+			if importLines[line] {
+				meta |= MetaInImport
+			}
+			if commentLines[line] {
+				meta |= MetaInComment
+			}
+			if name, ok := funcNameByLine[line]; ok && term == name {
+				meta |= MetaInFunctionName
+			}
+			if name, ok := methodNameByLine[line]; ok && term == name {
+				meta |= MetaInMethodName
+			}
+			if name, ok := typeNameByLine[line]; ok && term == name {
+				meta |= MetaInTypeName
+			}
+
+			tokenCounter++
+			tokens = append(tokens, RawToken{
+				Term:     strings.ToLower(term),
+				Position: tokenCounter,
+				Meta:     meta,
+			})
+		}
+	}
+
+	return tokens
+}
+
+var (
+	rePyFuncDef  = regexp.MustCompile(`^\s*def\s+([a-zA-Z_][a-zA-Z0-9_]*)`)
+	rePyClassDef = regexp.MustCompile(`^\s*class\s+([a-zA-Z_][a-zA-Z0-9_]*)`)
+	rePyImport   = regexp.MustCompile(`^\s*(import|from)\s+`)
+	rePyComment  = regexp.MustCompile(`#.*$`)
+)
+
+// tokenizePython has no AST parser available (go/parser is Go-only), so it
+// leans on per-language regexes instead of the generic func|def|class|struct
+// one: a line starting with "def"/"class" names a function or class, a
+// line starting with "import"/"from" is tagged MetaInImport, and anything
+// after a "#" is tagged MetaInComment.
+func tokenizePython(content []byte) []RawToken {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	var tokens []RawToken
+	tokenCounter := uint32(0)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		funcName, className := "", ""
+		if m := rePyFuncDef.FindStringSubmatch(line); m != nil {
+			funcName = m[1]
+		} else if m := rePyClassDef.FindStringSubmatch(line); m != nil {
+			className = m[1]
+		}
+		isImport := rePyImport.MatchString(line)
+
+		commentStart := -1
+		if loc := rePyComment.FindStringIndex(line); loc != nil {
+			commentStart = loc[0]
+		}
+
+		for _, loc := range reIdentifier.FindAllStringIndex(line, -1) {
+			term := line[loc[0]:loc[1]]
+			meta := uint8(MetaNone)
+
+			if commentStart >= 0 && loc[0] >= commentStart {
+				meta |= MetaInComment
+			}
+			if isImport {
+				meta |= MetaInImport
+			}
+			if term == funcName {
+				meta |= MetaInFunctionName
+			}
+			if term == className {
+				meta |= MetaInTypeName
+			}
+
+			tokenCounter++
+			tokens = append(tokens, RawToken{
+				Term:     strings.ToLower(term),
+				Position: tokenCounter,
+				Meta:     meta,
+			})
+		}
+	}
+
+	return tokens
+}
+
+var (
+	reJSFuncDef  = regexp.MustCompile(`\bfunction\s*\*?\s+([a-zA-Z_$][a-zA-Z0-9_$]*)`)
+	reJSMethod   = regexp.MustCompile(`^\s*(?:async\s+)?([a-zA-Z_$][a-zA-Z0-9_$]*)\s*\([^)]*\)\s*\{`)
+	reJSClassDef = regexp.MustCompile(`\bclass\s+([a-zA-Z_$][a-zA-Z0-9_$]*)`)
+	reJSImport   = regexp.MustCompile(`^\s*(import\s|export\s.*from\s)|require\(`)
+	reJSComment  = regexp.MustCompile(`//.*$`)
+)
+
+// tokenizeJSLike covers both JavaScript and TypeScript: "function name",
+// "class Name", and a bare "name(...) {" (a method/shorthand function)
+// name the enclosing declaration, import/require lines are tagged
+// MetaInImport, and "//..." is tagged MetaInComment. It doesn't attempt to
+// distinguish TS-only constructs (interfaces, type aliases) from plain
+// classes/functions - those still get indexed, just without a symbol-kind
+// bit of their own.
+func tokenizeJSLike(content []byte) []RawToken {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	var tokens []RawToken
+	tokenCounter := uint32(0)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		funcName, className := "", ""
+		if m := reJSFuncDef.FindStringSubmatch(line); m != nil {
+			funcName = m[1]
+		} else if m := reJSClassDef.FindStringSubmatch(line); m != nil {
+			className = m[1]
+		} else if m := reJSMethod.FindStringSubmatch(line); m != nil {
+			funcName = m[1]
+		}
+		isImport := reJSImport.MatchString(line)
+
+		commentStart := -1
+		if loc := reJSComment.FindStringIndex(line); loc != nil {
+			commentStart = loc[0]
+		}
+
+		for _, loc := range reIdentifier.FindAllStringIndex(line, -1) {
+			term := line[loc[0]:loc[1]]
+			meta := uint8(MetaNone)
+
+			if commentStart >= 0 && loc[0] >= commentStart {
+				meta |= MetaInComment
+			}
+			if isImport {
+				meta |= MetaInImport
+			}
+			if term == funcName {
+				meta |= MetaInFunctionName
+			}
+			if term == className {
+				meta |= MetaInTypeName
+			}
+
+			tokenCounter++
+			tokens = append(tokens, RawToken{
+				Term:     strings.ToLower(term),
+				Position: tokenCounter,
+				Meta:     meta,
+			})
+		}
+	}
+
+	return tokens
+}
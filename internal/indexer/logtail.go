@@ -0,0 +1,449 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"devscope/internal/store"
+	"devscope/pkg/models"
+)
+
+// logTailStateFile persists per-file read offsets and day-segment
+// assignments between LogTailer runs, so restarting the tailer doesn't
+// re-index every line from byte zero. Only the indexer package needs it,
+// so it lives here rather than in pkg/models alongside the shared on-disk
+// artifacts.
+const logTailStateFile = "logtail.state"
+
+// LogTailer incrementally indexes .log files in a directory as new lines
+// are appended, instead of waiting for the next full `devscope index` run.
+// It's meant to run alongside IndexBuilder, not replace it: a `devscope
+// index` run still owns the initial crawl and every code file's postings;
+// LogTailer only ever touches log DocumentRecords and a set of rolling
+// per-day segments that it keeps rewriting in place until the day rolls
+// over, at which point that day's segment is effectively done and a new
+// one starts.
+//
+// There's no fsnotify here - the module has no go.mod to pull a real
+// filesystem-watch dependency from, so Run polls Dir on PollInterval
+// instead of subscribing to kernel events. The on-disk result (postings,
+// segments, manifest) is identical to what a push-based watcher would
+// produce; only the latency differs.
+type LogTailer struct {
+	Dir          string
+	OutDir       string
+	PollInterval time.Duration
+
+	offsets   map[string]int64  // path -> last read byte offset
+	positions map[string]uint32 // path -> running token-position counter
+	docIDs    map[string]uint32 // path -> DocID
+	docs      map[string]models.DocumentRecord
+	nextDocID uint32
+
+	dayIndex map[string]map[string]map[uint32]*models.Posting // day ("20060102") -> term -> docID -> posting
+	daySeg   map[string]int                                    // day -> assigned segment number
+}
+
+func NewLogTailer(dir, outDir string) *LogTailer {
+	return &LogTailer{
+		Dir:          dir,
+		OutDir:       outDir,
+		PollInterval: 2 * time.Second,
+		offsets:      make(map[string]int64),
+		positions:    make(map[string]uint32),
+		docIDs:       make(map[string]uint32),
+		docs:         make(map[string]models.DocumentRecord),
+		dayIndex:     make(map[string]map[string]map[uint32]*models.Posting),
+		daySeg:       make(map[string]int),
+	}
+}
+
+// Run polls Dir for .log files until stop is closed, indexing newly
+// appended lines as it finds them and flushing after every poll. It
+// blocks, so callers typically run it in its own goroutine.
+func (t *LogTailer) Run(stop <-chan struct{}) error {
+	if err := t.loadState(); err != nil {
+		return fmt.Errorf("loading previous log-tail state: %w", err)
+	}
+
+	ticker := time.NewTicker(t.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.poll(); err != nil {
+			fmt.Printf("Warn: log tail poll failed: %v\n", err)
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll scans Dir for *.log files, indexes whatever's new in each, and
+// flushes the result to disk.
+func (t *LogTailer) poll() error {
+	entries, err := os.ReadDir(t.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(e.Name()), ".log") {
+			continue
+		}
+		path := filepath.Join(t.Dir, e.Name())
+		if err := t.pollFile(path); err != nil {
+			fmt.Printf("Warn: could not tail %s: %v\n", path, err)
+		}
+	}
+
+	return t.flush()
+}
+
+// pollFile reads whatever's been appended to path since the last poll and
+// indexes it a line at a time. Anything after the last newline is left
+// unread for the next poll, in case the writer is still mid-line.
+func (t *LogTailer) pollFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	lastOffset := t.offsets[path]
+	if info.Size() < lastOffset {
+		// file was truncated or rotated out from under us - start over.
+		lastOffset = 0
+		t.positions[path] = 0
+	}
+	if info.Size() == lastOffset {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	chunk := make([]byte, info.Size()-lastOffset)
+	if _, err := f.ReadAt(chunk, lastOffset); err != nil && err != io.EOF {
+		return err
+	}
+
+	lastNL := bytes.LastIndexByte(chunk, '\n')
+	if lastNL < 0 {
+		return nil // no complete line yet
+	}
+	complete := chunk[:lastNL]
+	newOffset := lastOffset + int64(lastNL) + 1
+
+	docID, ok := t.docIDs[path]
+	if !ok {
+		docID = t.allocDocID()
+		t.docIDs[path] = docID
+	}
+	doc := t.docs[path]
+	doc.DocID = docID
+	doc.Path = path
+	doc.Type = models.DocTypeLog
+
+	counter := t.positions[path]
+	for _, lineBytes := range bytes.Split(complete, []byte("\n")) {
+		line := string(lineBytes)
+		if line == "" {
+			continue
+		}
+
+		ts := parseTimestamp(line)
+		if ts > 0 {
+			if doc.TimestampMin == 0 || ts < doc.TimestampMin {
+				doc.TimestampMin = ts
+			}
+			if ts > doc.TimestampMax {
+				doc.TimestampMax = ts
+			}
+		}
+
+		meta := uint8(MetaNone)
+		upperLine := strings.ToUpper(line)
+		if strings.Contains(upperLine, "ERROR") {
+			meta |= MetaLogLevelError
+		} else if strings.Contains(upperLine, "WARN") {
+			meta |= MetaLogLevelWarn
+		}
+
+		day := dayBucket(ts)
+		for _, term := range reIdentifier.FindAllString(line, -1) {
+			counter++
+			t.addPosting(day, strings.ToLower(term), docID, counter, meta)
+		}
+	}
+
+	t.positions[path] = counter
+	t.offsets[path] = newOffset
+	t.docs[path] = doc
+	return nil
+}
+
+// dayBucket buckets a parsed log timestamp (or, failing that, the current
+// time) into the "20060102" key used to name rolling per-day segments.
+func dayBucket(ts int64) string {
+	if ts == 0 {
+		ts = time.Now().Unix()
+	}
+	return time.Unix(ts, 0).UTC().Format("20060102")
+}
+
+func (t *LogTailer) addPosting(day, term string, docID uint32, position uint32, meta uint8) {
+	terms, ok := t.dayIndex[day]
+	if !ok {
+		terms = make(map[string]map[uint32]*models.Posting)
+		t.dayIndex[day] = terms
+	}
+	docMap, ok := terms[term]
+	if !ok {
+		docMap = make(map[uint32]*models.Posting)
+		terms[term] = docMap
+	}
+	post, ok := docMap[docID]
+	if !ok {
+		post = &models.Posting{DocID: docID}
+		docMap[docID] = post
+	}
+	post.Frequency++
+	post.Positions = append(post.Positions, position)
+	post.Meta |= meta
+}
+
+func (t *LogTailer) allocDocID() uint32 {
+	id := t.nextDocID
+	t.nextDocID++
+	return id
+}
+
+// flush persists whatever's changed: rewrites docs.bin with the updated
+// log DocumentRecords (leaving every other record untouched) and rewrites
+// the rolling segment of every day held in dayIndex.
+func (t *LogTailer) flush() error {
+	if len(t.docs) == 0 {
+		return nil
+	}
+
+	if err := t.flushDocs(); err != nil {
+		return fmt.Errorf("flushing docs: %w", err)
+	}
+
+	segs, next := loadManifest(t.OutDir)
+	segSet := make(map[int]bool, len(segs))
+	for _, n := range segs {
+		segSet[n] = true
+	}
+
+	dirty := false
+	for day, terms := range t.dayIndex {
+		n, ok := t.daySeg[day]
+		if ok {
+			if _, err := os.Stat(filepath.Join(t.OutDir, segmentIdxName(n))); err != nil {
+				// Compacted away by a concurrent `devscope index` run -
+				// this day just gets a fresh segment number.
+				ok = false
+			}
+		}
+		if !ok {
+			n = next
+			next++
+			t.daySeg[day] = n
+		}
+
+		idxPath := filepath.Join(t.OutDir, segmentIdxName(n))
+		lexPath := filepath.Join(t.OutDir, segmentLexName(n))
+		if err := writeSegmentFiles(idxPath, lexPath, terms); err != nil {
+			return fmt.Errorf("writing day segment %s: %w", day, err)
+		}
+
+		if !segSet[n] {
+			segs = append(segs, n)
+			segSet[n] = true
+			dirty = true
+		}
+	}
+
+	if dirty {
+		if err := saveManifest(t.OutDir, segs); err != nil {
+			return err
+		}
+	}
+
+	return t.saveState()
+}
+
+// flushDocs rewrites docs.bin, replacing or inserting the DocumentRecord
+// for each log file the tailer has touched and leaving every other record
+// (code files, logs indexed by a separate `devscope index` run) as-is.
+func (t *LogTailer) flushDocs() error {
+	docsPath := filepath.Join(t.OutDir, models.DocsFileName)
+
+	existing := make(map[string]models.DocumentRecord)
+	nextDocID := uint32(1)
+	if dr, err := store.NewDocReader(docsPath); err == nil {
+		for {
+			rec, err := dr.ReadNext()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				dr.Close()
+				return err
+			}
+			existing[rec.Path] = rec
+		}
+		if dr.NextDocID > nextDocID {
+			nextDocID = dr.NextDocID
+		}
+		dr.Close()
+	}
+
+	for path, rec := range t.docs {
+		existing[path] = rec
+		if rec.DocID >= nextDocID {
+			nextDocID = rec.DocID + 1
+		}
+	}
+
+	dw, err := store.NewDocWriter(docsPath)
+	if err != nil {
+		return err
+	}
+	for _, rec := range existing {
+		if err := dw.Write(rec); err != nil {
+			dw.CloseWithNextDocID(nextDocID)
+			return err
+		}
+	}
+	return dw.CloseWithNextDocID(nextDocID)
+}
+
+// loadState restores per-path read offsets, doc IDs, and day-segment
+// assignments from a previous Run, and seeds nextDocID from docs.bin's
+// persisted counter so freshly discovered log files still get unique IDs.
+func (t *LogTailer) loadState() error {
+	if dr, err := store.NewDocReader(filepath.Join(t.OutDir, models.DocsFileName)); err == nil {
+		t.nextDocID = dr.NextDocID
+		for {
+			rec, err := dr.ReadNext()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				dr.Close()
+				return err
+			}
+			if rec.Type == models.DocTypeLog {
+				t.docs[rec.Path] = rec
+				t.docIDs[rec.Path] = rec.DocID
+			}
+		}
+		dr.Close()
+	}
+	if t.nextDocID == 0 {
+		t.nextDocID = 1
+	}
+
+	f, err := os.Open(filepath.Join(t.OutDir, logTailStateFile))
+	if err != nil {
+		return nil // no previous tailer state - first run
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "F":
+			// F <docID> <offset> <position> <path>
+			rest := strings.SplitN(fields[1], " ", 4)
+			if len(rest) != 4 {
+				continue
+			}
+			var docID uint32
+			var offset int64
+			var position uint32
+			fmt.Sscanf(rest[0], "%d", &docID)
+			fmt.Sscanf(rest[1], "%d", &offset)
+			fmt.Sscanf(rest[2], "%d", &position)
+			path := rest[3]
+
+			t.docIDs[path] = docID
+			t.offsets[path] = offset
+			t.positions[path] = position
+		case "D":
+			// D <day> <segNum>
+			rest := strings.SplitN(fields[1], " ", 2)
+			if len(rest) != 2 {
+				continue
+			}
+			var segNum int
+			fmt.Sscanf(rest[1], "%d", &segNum)
+			t.daySeg[rest[0]] = segNum
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// A restored daySeg entry points at a day's segment from before this
+	// restart; without loading its postings back into dayIndex, the next
+	// flush would call writeSegmentFiles with only whatever's indexed
+	// since the restart, overwriting that segment in place and silently
+	// dropping every posting indexed before it.
+	for day, segNum := range t.daySeg {
+		postings, err := readSegmentPostings(filepath.Join(t.OutDir, segmentIdxName(segNum)), filepath.Join(t.OutDir, segmentLexName(segNum)))
+		if err != nil {
+			// Compacted away by a concurrent `devscope index` run since we
+			// last saved state - flush's own ok-check already handles this
+			// by handing the day a fresh segment number, so starting it
+			// from empty here just matches that recovery path.
+			continue
+		}
+		terms := make(map[string]map[uint32]*models.Posting, len(postings))
+		for term, ps := range postings {
+			docMap := make(map[uint32]*models.Posting, len(ps))
+			for i := range ps {
+				p := ps[i]
+				docMap[p.DocID] = &p
+			}
+			terms[term] = docMap
+		}
+		t.dayIndex[day] = terms
+	}
+
+	return nil
+}
+
+func (t *LogTailer) saveState() error {
+	f, err := os.Create(filepath.Join(t.OutDir, logTailStateFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for path, docID := range t.docIDs {
+		fmt.Fprintf(w, "F %d %d %d %s\n", docID, t.offsets[path], t.positions[path], path)
+	}
+	for day, segNum := range t.daySeg {
+		fmt.Fprintf(w, "D %s %d\n", day, segNum)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,66 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLogTailerRestartPreservesDayPostings covers chunk0-5: loadState
+// restored offsets/docIDs/daySeg but never repopulated dayIndex, so a
+// restarted tailer's first flush overwrote the existing day segment with
+// only whatever it had indexed since the restart, silently dropping every
+// posting from before it.
+func TestLogTailerRestartPreservesDayPostings(t *testing.T) {
+	dir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), ".devscope")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	logPath := filepath.Join(dir, "app.log")
+	write := func(content string) {
+		if err := os.WriteFile(logPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("firstTerm one\nsecondTerm two\n")
+
+	tailer := NewLogTailer(dir, outDir)
+	if err := tailer.loadState(); err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if err := tailer.poll(); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	day := dayBucket(0)
+	if _, ok := tailer.dayIndex[day]["firstterm"]; !ok {
+		t.Fatalf("expected firstterm indexed before restart, dayIndex=%v", tailer.dayIndex[day])
+	}
+
+	// Simulate a restart: a fresh LogTailer with no in-memory state, only
+	// what's on disk.
+	restarted := NewLogTailer(dir, outDir)
+	if err := restarted.loadState(); err != nil {
+		t.Fatalf("loadState after restart: %v", err)
+	}
+	if _, ok := restarted.dayIndex[day]["firstterm"]; !ok {
+		t.Fatalf("expected loadState to restore firstterm's posting from the existing day segment, dayIndex=%v", restarted.dayIndex[day])
+	}
+	if _, ok := restarted.dayIndex[day]["secondterm"]; !ok {
+		t.Fatalf("expected loadState to restore secondterm's posting from the existing day segment, dayIndex=%v", restarted.dayIndex[day])
+	}
+
+	write("firstTerm one\nsecondTerm two\nthirdTerm three\n")
+	if err := restarted.poll(); err != nil {
+		t.Fatalf("poll after restart: %v", err)
+	}
+
+	for _, term := range []string{"firstterm", "secondterm", "thirdterm"} {
+		if _, ok := restarted.dayIndex[day][term]; !ok {
+			t.Fatalf("expected %q's posting to survive the restart, dayIndex=%v", term, restarted.dayIndex[day])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package indexer
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// pathHierarchyTerms emits a file's path as a set of terms usable for
+// path-scoped queries: every directory prefix ("internal",
+// "internal/indexer"), the full path ("internal/indexer/tokenize.go"), the
+// basename ("tokenize.go"), and its extension-stripped stem ("tokenize").
+// query.Search's path:/file: filters match against these the same way
+// level:/ext: match against other posting metadata.
+func pathHierarchyTerms(path string) []string {
+	clean := filepath.ToSlash(filepath.Clean(path))
+	clean = strings.TrimPrefix(clean, "./")
+	clean = strings.TrimPrefix(clean, "/")
+	parts := strings.Split(clean, "/")
+
+	terms := make([]string, 0, len(parts)+2)
+	for i := 1; i <= len(parts); i++ {
+		terms = append(terms, strings.Join(parts[:i], "/"))
+	}
+
+	base := parts[len(parts)-1]
+	terms = append(terms, base)
+
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	if stem != base {
+		terms = append(terms, stem)
+	}
+
+	return terms
+}
+
+// addPathTerms indexes doc's path under MetaInFileName so it's reachable
+// both by query.Search's path:/file: filters and by plain term search
+// (e.g. "search tokenize" matching tokenize.go by name). There's no spare
+// Meta bit left for a dedicated MetaInPath (see tokenizer.go), so these
+// reuse MetaInFileName; they're the only tokens Build tags with that bit.
+func (b *IndexBuilder) addPathTerms(path string, docID uint32) {
+	for _, term := range pathHierarchyTerms(path) {
+		b.addToken(RawToken{
+			Term:     strings.ToLower(term),
+			Position: 0,
+			Meta:     MetaInFileName,
+		}, docID)
+	}
+}
@@ -0,0 +1,38 @@
+package indexer
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestPathHierarchyTerms covers chunk1-3's path-scoped query terms: every
+// directory prefix, the full path, the basename, and its extension-stripped
+// stem.
+func TestPathHierarchyTerms(t *testing.T) {
+	got := pathHierarchyTerms("internal/indexer/tokenizer.go")
+	want := []string{
+		"internal",
+		"internal/indexer",
+		"internal/indexer/tokenizer.go",
+		"tokenizer.go",
+		"tokenizer",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// TestPathHierarchyTermsNoExtension covers a basename with no extension:
+// there's nothing for TrimSuffix to strip, so no separate stem term is
+// emitted on top of the prefix term and the basename term.
+func TestPathHierarchyTermsNoExtension(t *testing.T) {
+	got := pathHierarchyTerms("Makefile")
+	// A single-segment path's "full path" prefix term is just the basename
+	// itself, so it's already in terms once before the explicit basename
+	// append; Makefile has no extension, so stem == base and the
+	// "stem != base" guard means the stem isn't appended a third time.
+	want := []string{"Makefile", "Makefile"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package indexer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devscope/internal/indexer"
+	"devscope/internal/query"
+)
+
+// TestPhraseQueryHonorsLevelFilter covers chunk1-4: level:/kind: filters
+// were applied to single-term postings in processPostings but never
+// threaded through phrase matching, so a phrase query like
+// level:error "connection refused" matched a phrase that only ever
+// appeared on an INFO line.
+func TestPhraseQueryHonorsLevelFilter(t *testing.T) {
+	root := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), ".devscope")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	log := "INFO connection refused from peer\nERROR timeout occurred\n"
+	if err := os.WriteFile(filepath.Join(root, "app.log"), []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := indexer.NewIndexBuilder(outDir).Build(root); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	idx, err := query.NewIndexReader(outDir)
+	if err != nil {
+		t.Fatalf("opening index: %v", err)
+	}
+	defer idx.Close()
+
+	results, err := query.Search(idx, `level:error "connection refused"`, query.NewBM25Scorer())
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected 0 results: the phrase only appears on the INFO line, not the ERROR line, got %+v", results)
+	}
+
+	// Sanity check: without the level: filter, the phrase still matches.
+	results, err = query.Search(idx, `"connection refused"`, query.NewBM25Scorer())
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result for the unfiltered phrase query, got %d: %+v", len(results), results)
+	}
+}
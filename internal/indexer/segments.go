@@ -0,0 +1,393 @@
+package indexer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"devscope/pkg/models"
+)
+
+const (
+	// maxActiveSegments is how many live segments we tolerate before
+	// compacting the two oldest into one. Keeps query-time fan-out and
+	// open-file-descriptor counts bounded as a corpus grows.
+	maxActiveSegments = 4
+
+	// defaultSegmentFlushEvery documents before memIndex gets flushed out
+	// as a new immutable segment instead of growing without bound.
+	defaultSegmentFlushEvery = 2000
+)
+
+func segmentIdxName(n int) string { return fmt.Sprintf("seg-%06d.idx", n) }
+func segmentLexName(n int) string { return fmt.Sprintf("seg-%06d.lex", n) }
+
+// loadManifest returns the live segment numbers in oldest-first order, and
+// the next unused segment number.
+func loadManifest(outDir string) ([]int, int) {
+	f, err := os.Open(filepath.Join(outDir, models.SegmentManifestName))
+	if err != nil {
+		return nil, 1
+	}
+	defer f.Close()
+
+	var segs []int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var n int
+		if _, err := fmt.Sscanf(scanner.Text(), "%d", &n); err == nil {
+			segs = append(segs, n)
+		}
+	}
+
+	next := 1
+	for _, n := range segs {
+		if n >= next {
+			next = n + 1
+		}
+	}
+	return segs, next
+}
+
+func saveManifest(outDir string, segs []int) error {
+	f, err := os.Create(filepath.Join(outDir, models.SegmentManifestName))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, n := range segs {
+		fmt.Fprintf(w, "%d\n", n)
+	}
+	return w.Flush()
+}
+
+// flushSegment writes the current in-memory postings out as a new,
+// immutable SSTable-style segment (seg-NNNNNN.idx/.lex), appends it to the
+// manifest, and clears memIndex so the next batch of documents accumulates
+// from empty. Called every SegmentFlushEvery documents, and once more at
+// the end of Build for whatever's left over.
+func (b *IndexBuilder) flushSegment(outDir string) error {
+	if len(b.memIndex) == 0 {
+		return nil
+	}
+
+	segs, next := loadManifest(outDir)
+
+	idxPath := filepath.Join(outDir, segmentIdxName(next))
+	lexPath := filepath.Join(outDir, segmentLexName(next))
+	if err := writeSegmentFiles(idxPath, lexPath, b.memIndex); err != nil {
+		return err
+	}
+
+	b.memIndex = make(map[string]map[uint32]*models.Posting)
+
+	segs = append(segs, next)
+	if err := saveManifest(outDir, segs); err != nil {
+		return err
+	}
+
+	return b.compact(outDir)
+}
+
+// compact merges the two oldest segments into one once there are more than
+// maxActiveSegments live. The merge itself runs on its own goroutine to
+// model a background compactor; since `devscope index` is a one-shot CLI
+// process with no long-lived background worker to hand this off to, Build
+// still waits for it to finish before returning, but the approach (and the
+// on-disk format) is the same one a persistent indexer would use to merge
+// without blocking new writes.
+func (b *IndexBuilder) compact(outDir string) error {
+	segs, next := loadManifest(outDir)
+	if len(segs) <= maxActiveSegments {
+		return nil
+	}
+
+	oldA, oldB := segs[0], segs[1]
+	newSeg := next
+
+	var wg sync.WaitGroup
+	var mergeErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mergeErr = mergeSegments(outDir, oldA, oldB, newSeg)
+	}()
+	wg.Wait()
+	if mergeErr != nil {
+		return fmt.Errorf("compacting segments %d+%d: %w", oldA, oldB, mergeErr)
+	}
+
+	for _, path := range []string{
+		filepath.Join(outDir, segmentIdxName(oldA)),
+		filepath.Join(outDir, segmentLexName(oldA)),
+		filepath.Join(outDir, segmentIdxName(oldB)),
+		filepath.Join(outDir, segmentLexName(oldB)),
+	} {
+		if err := os.Remove(path); err != nil {
+			fmt.Printf("Warn: could not remove compacted segment file %s: %v\n", path, err)
+		}
+	}
+
+	remaining := append([]int{newSeg}, segs[2:]...)
+	return saveManifest(outDir, remaining)
+}
+
+// clearSegments removes every live segment file and resets the manifest,
+// called once at the start of each Build() run (see builder.go). Build()
+// re-crawls and re-adds every currently-present file to memIndex each run -
+// cache hits included, not just changed files - so the segments it's about
+// to flush already cover the complete current corpus on their own. Leaving
+// the previous run's segments in place alongside them would reintroduce
+// the same DocID under two segments wherever a file's content changed
+// (stale postings from the old content lingering next to the new ones) and
+// leak postings for files that were deleted or renamed away from, since
+// nothing else drops a stale posting short of compaction happening to pick
+// that exact segment. Wiping first keeps mergeSegments'/GetPostings'
+// "DocIDs are disjoint across segments" invariant actually true.
+func clearSegments(outDir string) error {
+	segs, _ := loadManifest(outDir)
+	for _, n := range segs {
+		for _, path := range []string{
+			filepath.Join(outDir, segmentIdxName(n)),
+			filepath.Join(outDir, segmentLexName(n)),
+		} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	if err := os.Remove(filepath.Join(outDir, models.SegmentManifestName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// mergeSegments k-way-merges two segments' postings by term. DocIDs are
+// disjoint between segments because clearSegments wipes every segment from
+// the previous Build() run before this one starts, so a document only ever
+// lives in the one segment it was flushed into this run.
+func mergeSegments(outDir string, segA, segB, newSeg int) error {
+	postingsA, err := readSegmentPostings(filepath.Join(outDir, segmentIdxName(segA)), filepath.Join(outDir, segmentLexName(segA)))
+	if err != nil {
+		return err
+	}
+	postingsB, err := readSegmentPostings(filepath.Join(outDir, segmentIdxName(segB)), filepath.Join(outDir, segmentLexName(segB)))
+	if err != nil {
+		return err
+	}
+
+	merged := make(map[string]map[uint32]*models.Posting)
+	merge := func(src map[string][]models.Posting) {
+		for term, postings := range src {
+			docMap, ok := merged[term]
+			if !ok {
+				docMap = make(map[uint32]*models.Posting)
+				merged[term] = docMap
+			}
+			for _, p := range postings {
+				pCopy := p
+				docMap[pCopy.DocID] = &pCopy
+			}
+		}
+	}
+	merge(postingsA)
+	merge(postingsB)
+
+	idxPath := filepath.Join(outDir, segmentIdxName(newSeg))
+	lexPath := filepath.Join(outDir, segmentLexName(newSeg))
+	return writeSegmentFiles(idxPath, lexPath, merged)
+}
+
+// readSegmentLexicon loads a segment's .lex file in full.
+func readSegmentLexicon(lexPath string) (map[string]models.LexiconEntry, error) {
+	f, err := os.Open(lexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header) != "DEVSCOPE_LEX" {
+		return nil, fmt.Errorf("bad lexicon header in %s", lexPath)
+	}
+	if _, err := r.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]models.LexiconEntry)
+	for {
+		var termLen uint16
+		if err := binary.Read(r, binary.LittleEndian, &termLen); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		termBytes := make([]byte, termLen)
+		if _, err := io.ReadFull(r, termBytes); err != nil {
+			return nil, err
+		}
+		meta := make([]byte, 16)
+		if _, err := io.ReadFull(r, meta); err != nil {
+			return nil, err
+		}
+		entries[string(termBytes)] = models.LexiconEntry{
+			Term:         string(termBytes),
+			DocFreq:      binary.LittleEndian.Uint32(meta[0:4]),
+			Offset:       binary.LittleEndian.Uint64(meta[4:12]),
+			PostingCount: binary.LittleEndian.Uint32(meta[12:16]),
+		}
+	}
+	return entries, nil
+}
+
+// readSegmentPostings decodes every posting list in a segment, keyed by
+// term. Only used by compaction, which needs the whole segment anyway; the
+// query side uses mmap + per-term lookups instead (see query/segments.go).
+func readSegmentPostings(idxPath, lexPath string) (map[string][]models.Posting, error) {
+	lex, err := readSegmentLexicon(lexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 13 || string(data[:12]) != "DEVSCOPE_IDX" {
+		return nil, fmt.Errorf("segment %s: invalid index header", idxPath)
+	}
+	version := data[12]
+
+	out := make(map[string][]models.Posting, len(lex))
+	for term, entry := range lex {
+		start := entry.Offset
+		end := start + uint64(entry.PostingCount)
+		if end > uint64(len(data)) {
+			return nil, fmt.Errorf("segment %s: posting list for %q out of bounds", idxPath, term)
+		}
+		region := data[start:end]
+
+		var postings []models.Posting
+		switch version {
+		case idxVersionV1:
+			postings, err = decodePostingsV1(region, entry.DocFreq)
+		case idxVersionV2:
+			postings, err = decodePostingsV2(region, entry.DocFreq)
+		default:
+			err = fmt.Errorf("unsupported index version %d", version)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("segment %s: term %q: %w", idxPath, term, err)
+		}
+		out[term] = postings
+	}
+	return out, nil
+}
+
+// decodePostingsV1 decodes the original fixed-width posting format: per
+// posting, DocID(4) + Frequency(4) + Meta(1) + PosCount(4) + Positions(4
+// each), back to back with no skip list.
+func decodePostingsV1(region []byte, docFreq uint32) ([]models.Posting, error) {
+	postings := make([]models.Posting, 0, docFreq)
+	off := 0
+	for i := uint32(0); i < docFreq; i++ {
+		if off+13 > len(region) {
+			return nil, fmt.Errorf("posting list truncated")
+		}
+		p := models.Posting{
+			DocID:     binary.LittleEndian.Uint32(region[off : off+4]),
+			Frequency: binary.LittleEndian.Uint32(region[off+4 : off+8]),
+			Meta:      region[off+8],
+		}
+		posCount := binary.LittleEndian.Uint32(region[off+9 : off+13])
+		off += 13
+
+		posEnd := off + 4*int(posCount)
+		if posEnd > len(region) {
+			return nil, fmt.Errorf("posting list truncated (positions)")
+		}
+		p.Positions = make([]uint32, posCount)
+		for j := uint32(0); j < posCount; j++ {
+			p.Positions[j] = binary.LittleEndian.Uint32(region[off : off+4])
+			off += 4
+		}
+		postings = append(postings, p)
+	}
+	return postings, nil
+}
+
+// decodePostingsV2 decodes the delta+varint format written by
+// encodePostingsV2, skipping past the leading skip list (not needed for a
+// full decode - see query.IndexReader.GetPostingsFrom for the seeking path
+// that uses it).
+func decodePostingsV2(region []byte, docFreq uint32) ([]models.Posting, error) {
+	if len(region) < 4 {
+		return nil, fmt.Errorf("posting list truncated (skip count)")
+	}
+	skipCount := binary.LittleEndian.Uint32(region[0:4])
+	blobStart := 4 + int(skipCount)*8
+	if blobStart > len(region) {
+		return nil, fmt.Errorf("posting list truncated (skip table)")
+	}
+
+	postings := make([]models.Posting, 0, docFreq)
+	off := blobStart
+	prevDocID := uint32(0)
+	for i := uint32(0); i < docFreq; i++ {
+		docDelta, n := binary.Uvarint(region[off:])
+		if n <= 0 {
+			return nil, fmt.Errorf("posting list corrupt (docid varint)")
+		}
+		off += n
+		prevDocID += uint32(docDelta)
+
+		freq, n := binary.Uvarint(region[off:])
+		if n <= 0 {
+			return nil, fmt.Errorf("posting list corrupt (freq varint)")
+		}
+		off += n
+
+		if off >= len(region) {
+			return nil, fmt.Errorf("posting list truncated (meta)")
+		}
+		meta := region[off]
+		off++
+
+		posCount, n := binary.Uvarint(region[off:])
+		if n <= 0 {
+			return nil, fmt.Errorf("posting list corrupt (poscount varint)")
+		}
+		off += n
+
+		positions := make([]uint32, posCount)
+		prevPos := uint32(0)
+		for j := range positions {
+			delta, n := binary.Uvarint(region[off:])
+			if n <= 0 {
+				return nil, fmt.Errorf("posting list corrupt (position varint)")
+			}
+			off += n
+			prevPos += uint32(delta)
+			positions[j] = prevPos
+		}
+
+		postings = append(postings, models.Posting{
+			DocID:     prevDocID,
+			Frequency: uint32(freq),
+			Meta:      meta,
+			Positions: positions,
+		})
+	}
+	return postings, nil
+}
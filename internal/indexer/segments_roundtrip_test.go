@@ -0,0 +1,72 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"testing"
+
+	"devscope/pkg/models"
+)
+
+// TestEncodeDecodePostingsV2RoundTrip covers chunk0-7's delta+varint posting
+// format: encodePostingsV2 must produce a blob decodePostingsV2 can read
+// back byte-for-byte, including DocID gaps and position gaps that aren't a
+// neat multiple of skipInterval.
+func TestEncodeDecodePostingsV2RoundTrip(t *testing.T) {
+	postings := []*models.Posting{
+		{DocID: 1, Frequency: 2, Meta: 0x1, Positions: []uint32{5, 1}},
+		{DocID: 2, Frequency: 1, Meta: 0x2, Positions: []uint32{0}},
+		{DocID: 9, Frequency: 3, Meta: 0x3, Positions: []uint32{40, 12, 12, 100}},
+		{DocID: 300, Frequency: 1, Meta: 0x0, Positions: nil},
+	}
+
+	blob, skip := encodePostingsV2(postings)
+	if len(skip) == 0 {
+		t.Fatal("expected at least one skip entry")
+	}
+	if skip[0].docID != postings[0].DocID {
+		t.Fatalf("expected first skip entry to cover the first posting's DocID %d, got %d", postings[0].DocID, skip[0].docID)
+	}
+
+	// decodePostingsV2 expects the same layout writeSegmentFiles puts on
+	// disk: a 4-byte skip count, then skipCount*8 bytes of (docID, offset)
+	// pairs, then the blob.
+	var region bytes.Buffer
+	var countBuf [4]byte
+	binary.LittleEndian.PutUint32(countBuf[:], uint32(len(skip)))
+	region.Write(countBuf[:])
+	var entryBuf [8]byte
+	for _, se := range skip {
+		binary.LittleEndian.PutUint32(entryBuf[0:4], se.docID)
+		binary.LittleEndian.PutUint32(entryBuf[4:8], se.offset)
+		region.Write(entryBuf[:])
+	}
+	region.Write(blob)
+
+	decoded, err := decodePostingsV2(region.Bytes(), uint32(len(postings)))
+	if err != nil {
+		t.Fatalf("decodePostingsV2: %v", err)
+	}
+	if len(decoded) != len(postings) {
+		t.Fatalf("expected %d postings, got %d", len(postings), len(decoded))
+	}
+
+	for i, want := range postings {
+		got := decoded[i]
+		// Positions come back sorted (see encodePostingsV2's doc comment),
+		// not necessarily in the order they were collected; decodePostingsV2
+		// always allocates a (possibly zero-length) slice, so a nil want is
+		// compared against an empty one rather than nil.
+		wantPositions := append([]uint32{}, want.Positions...)
+		sort.Slice(wantPositions, func(i, j int) bool { return wantPositions[i] < wantPositions[j] })
+
+		if got.DocID != want.DocID || got.Frequency != want.Frequency || got.Meta != want.Meta {
+			t.Fatalf("posting %d: got %+v, want DocID=%d Frequency=%d Meta=%d", i, got, want.DocID, want.Frequency, want.Meta)
+		}
+		if !reflect.DeepEqual(got.Positions, wantPositions) {
+			t.Fatalf("posting %d positions: got %v, want %v", i, got.Positions, wantPositions)
+		}
+	}
+}
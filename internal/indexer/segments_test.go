@@ -0,0 +1,72 @@
+package indexer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devscope/internal/indexer"
+	"devscope/internal/query"
+)
+
+// TestBuildDoesNotDuplicatePostingsOnReindex covers chunk0-4's "DocIDs are
+// disjoint across segments" invariant, which breaks once a file's DocID is
+// reused across an edit + reindex (chunk0-1/chunk1-5): without clearing the
+// previous run's segments, the edited file's old and new postings for the
+// same term both survive, and processPostings double-counts a single
+// required term against itself - AND-matching then either drops a
+// genuinely matching doc (count never reaches totalRequirements exactly, or
+// overshoots it) or lets an extra required term ride along for free.
+func TestBuildDoesNotDuplicatePostingsOnReindex(t *testing.T) {
+	root := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), ".devscope")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, "a.go")
+
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("package main\n\nfunc HTTPHandler() {}\n")
+	if err := indexer.NewIndexBuilder(outDir).Build(root); err != nil {
+		t.Fatalf("initial Build: %v", err)
+	}
+
+	// Edit the file (same path, so the DocID is reused) and reindex without
+	// ever accumulating enough segments to trigger count-based compaction.
+	write("package main\n\nfunc HTTPHandler() {}\n\nfunc anotherFunc() {}\n")
+	if err := indexer.NewIndexBuilder(outDir).Build(root); err != nil {
+		t.Fatalf("Build after edit: %v", err)
+	}
+
+	idx, err := query.NewIndexReader(outDir)
+	if err != nil {
+		t.Fatalf("opening index: %v", err)
+	}
+	defer idx.Close()
+
+	results, err := query.Search(idx, "HTTPHandler", query.NewBM25Scorer())
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected exactly 1 result for a term present before and after the edit, got %d: %+v", len(results), results)
+	}
+	if results[0].MatchCount != 1 {
+		t.Fatalf("expected MatchCount 1 (not double-counted from a stale duplicate posting), got %d", results[0].MatchCount)
+	}
+
+	// A second required term that matches nothing real must not be
+	// satisfied for free by a duplicated posting of the first term.
+	results, err = query.Search(idx, "HTTPHandler doesNotExistAnywhere", query.NewBM25Scorer())
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected 0 results for a query with one nonexistent required term, got %d: %+v", len(results), results)
+	}
+}
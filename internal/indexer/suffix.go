@@ -0,0 +1,69 @@
+package indexer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"index/suffixarray"
+	"os"
+)
+
+// suffixDocOffset maps a run of bytes in the concatenated suffix buffer
+// back to the document and in-file byte offset it came from.
+type suffixDocOffset struct {
+	DocID  uint32
+	Start  int64
+	Length int64
+}
+
+const (
+	SuffixHeader  = "DEVSCOPE_SUF"
+	SuffixVersion = 1
+)
+
+// saveSuffix builds a suffix array over the concatenation of every indexed
+// document's raw bytes (b.suffixBuf) and writes it to SuffixPath, alongside
+// a table mapping buffer offsets back to (DocID, in-file offset). This
+// gives query.SubstringSearch grep-like power over arbitrary substrings
+// and regexes, including ones the tokenizer would never emit as terms
+// (punctuation, partial identifiers, log fragments like "code=5").
+func (b *IndexBuilder) saveSuffix() error {
+	f, err := os.Create(b.SuffixPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	if _, err := w.WriteString(SuffixHeader); err != nil {
+		return err
+	}
+	if err := w.WriteByte(SuffixVersion); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(b.suffixDocOffsets))); err != nil {
+		return err
+	}
+	for _, o := range b.suffixDocOffsets {
+		if err := binary.Write(w, binary.LittleEndian, o.DocID); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, o.Start); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, o.Length); err != nil {
+			return err
+		}
+	}
+
+	// suffixarray.Index.Write serializes both the original data and the
+	// suffix array itself, so this is all query.loadSuffixIndex needs to rebuild
+	// a searchable Index.
+	sa := suffixarray.New(b.suffixBuf)
+	if err := sa.Write(w); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
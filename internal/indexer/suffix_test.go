@@ -0,0 +1,62 @@
+package indexer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devscope/internal/indexer"
+	"devscope/internal/query"
+)
+
+// TestSubstringSearchDoesNotCrossDocumentBoundary covers chunk0-3: the
+// suffix buffer concatenates every document's raw bytes, so without a
+// delimiter between them a pattern spanning the tail of one file and the
+// head of the next would wrongly be reported as a hit in the first file,
+// even though that text never appears in any single file.
+func TestSubstringSearchDoesNotCrossDocumentBoundary(t *testing.T) {
+	root := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), ".devscope")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "file1.txt"), []byte("foo_END"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b", "file2.txt"), []byte("START_bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := indexer.NewIndexBuilder(outDir).Build(root); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	idx, err := query.NewIndexReader(outDir)
+	if err != nil {
+		t.Fatalf("opening index: %v", err)
+	}
+	defer idx.Close()
+
+	results, err := query.SubstringSearch(idx, "foo_ENDSTART_bar")
+	if err != nil {
+		t.Fatalf("SubstringSearch: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no match spanning two documents, got %+v", results)
+	}
+
+	// Sanity check: a pattern that's genuinely within one file still matches.
+	results, err = query.SubstringSearch(idx, "foo_END")
+	if err != nil {
+		t.Fatalf("SubstringSearch: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 match for a pattern within a single document, got %d: %+v", len(results), results)
+	}
+}
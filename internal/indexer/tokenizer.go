@@ -2,8 +2,10 @@ package indexer
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,14 +25,35 @@ const (
 	MetaInFunctionName = 1 << 1
 	MetaLogLevelError  = 1 << 2
 	MetaLogLevelWarn   = 1 << 3
+
+	// The remaining four bits of Meta (a uint8) are spent on per-language
+	// symbol kinds from the language-aware tokenizers in language.go.
+	// MetaInString didn't make the cut - there wasn't a bit left for it.
+	MetaInTypeName   = 1 << 4
+	MetaInMethodName = 1 << 5
+	MetaInImport     = 1 << 6
+	MetaInComment    = 1 << 7
 )
 
-// helper to decide which tokenizer function to use
-func Tokenize(reader io.Reader, docType models.DocType) ([]RawToken, int64, int64) {
+// helper to decide which tokenizer function to use. For code, the file is
+// read in full up front and handed to whichever per-language tokenizer
+// DetectLanguage picks (see language.go) - tokenizeCode below is only the
+// fallback for languages without a dedicated one.
+func Tokenize(reader io.Reader, docType models.DocType, path string) ([]RawToken, int64, int64) {
 	if docType == models.DocTypeLog {
 		return tokenizeLog(reader)
 	}
-	return tokenizeCode(reader), 0, 0
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, 0
+	}
+
+	lang := DetectLanguage(path, content)
+	if fn, ok := languageTokenizers[lang]; ok {
+		return fn(content), 0, 0
+	}
+	return tokenizeCode(bytes.NewReader(content)), 0, 0
 }
 
 var (
@@ -113,7 +136,39 @@ func tokenizeLog(reader io.Reader) ([]RawToken, int64, int64) {
 	return tokens, minTime, maxTime
 }
 
+var (
+	reEpochMillis = regexp.MustCompile(`^\d{13}\b`)
+	reRFC3339     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+	reSyslog      = regexp.MustCompile(`^[A-Z][a-z]{2}\s+\d{1,2} \d{2}:\d{2}:\d{2}`)
+)
+
+// parseTimestamp extracts a unix-seconds timestamp from a log line's
+// prefix, trying progressively looser formats: epoch-millis (e.g. a raw
+// `1704200645123 ...`), RFC3339 with a timezone offset, syslog's year-less
+// `Jan _2 15:04:05`, and finally the plain ISO `YYYY-MM-DD HH:MM:SS` chunk
+// this originally only understood. A line matching none of them (or too
+// short to hold any of them) yields 0, meaning "no timestamp found".
 func parseTimestamp(line string) int64 {
+	if m := reEpochMillis.FindString(line); m != "" {
+		if ms, err := strconv.ParseInt(m, 10, 64); err == nil {
+			return ms / 1000
+		}
+	}
+
+	if m := reRFC3339.FindString(line); m != "" {
+		if t, err := time.Parse(time.RFC3339Nano, m); err == nil {
+			return t.Unix()
+		}
+	}
+
+	if m := reSyslog.FindString(line); m != "" {
+		if t, err := time.Parse("Jan _2 15:04:05", m); err == nil {
+			now := time.Now()
+			t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+			return t.Unix()
+		}
+	}
+
 	// we need at least some chars to make a date
 	if len(line) < 19 {
 		return 0
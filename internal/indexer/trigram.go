@@ -0,0 +1,104 @@
+package indexer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"sort"
+)
+
+const (
+	TrigramHeader  = "DEVSCOPE_TRI"
+	TrigramVersion = 1
+)
+
+// addTrigramDoc records every distinct 3-byte substring of content against
+// docID in b.trigramIndex. This runs alongside Tokenize rather than
+// replacing it: the token postings answer "which docs contain this
+// identifier", while trigrams let query.RegexSearch narrow candidate docs
+// for patterns the tokenizer would never emit (partial identifiers,
+// punctuation, arbitrary regexes) without having to regexp.Match every
+// indexed file.
+func (b *IndexBuilder) addTrigramDoc(docID uint32, content []byte) {
+	if len(content) < 3 {
+		return
+	}
+	if b.trigramIndex == nil {
+		b.trigramIndex = make(map[string]map[uint32]struct{})
+	}
+
+	seen := make(map[string]struct{})
+	for i := 0; i+3 <= len(content); i++ {
+		tri := string(content[i : i+3])
+		if _, ok := seen[tri]; ok {
+			continue
+		}
+		seen[tri] = struct{}{}
+
+		docSet, ok := b.trigramIndex[tri]
+		if !ok {
+			docSet = make(map[uint32]struct{})
+			b.trigramIndex[tri] = docSet
+		}
+		docSet[docID] = struct{}{}
+	}
+}
+
+// saveTrigrams writes trigram.bin: every trigram that appears in the
+// corpus, each followed by its sorted, delta+varint-encoded DocID list
+// (the same encoding scheme as posting lists - see encodePostingsV2).
+func (b *IndexBuilder) saveTrigrams() error {
+	f, err := os.Create(b.TrigramPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	if _, err := w.WriteString(TrigramHeader); err != nil {
+		return err
+	}
+	if err := w.WriteByte(TrigramVersion); err != nil {
+		return err
+	}
+
+	trigrams := make([]string, 0, len(b.trigramIndex))
+	for tri := range b.trigramIndex {
+		trigrams = append(trigrams, tri)
+	}
+	sort.Strings(trigrams)
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(trigrams))); err != nil {
+		return err
+	}
+
+	varintBuf := make([]byte, binary.MaxVarintLen64)
+	for _, tri := range trigrams {
+		if _, err := w.WriteString(tri); err != nil {
+			return err
+		}
+
+		docSet := b.trigramIndex[tri]
+		docIDs := make([]uint32, 0, len(docSet))
+		for id := range docSet {
+			docIDs = append(docIDs, id)
+		}
+		sort.Slice(docIDs, func(i, j int) bool { return docIDs[i] < docIDs[j] })
+
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(docIDs))); err != nil {
+			return err
+		}
+
+		prev := uint32(0)
+		for _, id := range docIDs {
+			n := binary.PutUvarint(varintBuf, uint64(id-prev))
+			if _, err := w.Write(varintBuf[:n]); err != nil {
+				return err
+			}
+			prev = id
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package query
+
+import "os"
+
+// mmapFile falls back to a plain read on platforms without POSIX mmap
+// support. Posting decoding still slices into this buffer the same way, it
+// just doesn't get the lazy page-in behavior of a real mmap.
+func mmapFile(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, info.Size())
+	if _, err := f.ReadAt(data, 0); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package query
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the whole file read-only into the process's address space.
+// Posting lists are then decoded as slices directly into this region
+// instead of being copied out via Seek+ReadFull, so cold search latency is
+// dominated by page faults on the terms actually touched rather than the
+// size of the index as a whole.
+func mmapFile(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func munmapFile(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return syscall.Munmap(data)
+}
@@ -1,19 +1,39 @@
 package query
 
 import (
-	"bufio"
+	"devscope/internal/store"
 	"devscope/pkg/models"
-	"encoding/binary"
 	"fmt"
+	"index/suffixarray"
 	"io"
-	"os"
+	"sort"
 )
 
 type IndexReader struct {
 	Docs      map[uint32]models.DocumentRecord
-	Lexicon   map[string]models.LexiconEntry
-	File      *os.File
+	Lexicon   map[string]models.LexiconEntry // aggregated DocFreq across segments, used for IDF
 	TotalDocs int
+
+	// AvgDocLen is the corpus-wide average document length (in tokens) at
+	// last index time, loaded from docs.bin's header. Scorer implementations
+	// use it to normalize for document length (see BM25Scorer).
+	AvgDocLen float64
+
+	// segments are the live on-disk postings segments, oldest first, as
+	// listed in segments.manifest. A term's full posting list is whatever
+	// GetPostings finds by merging across all of them. See segments.go.
+	segments []*segmentReader
+
+	// Suffix is the suffix array over every indexed document's raw bytes,
+	// used by SubstringSearch. It's optional: older indexes built before
+	// suffix.bin existed just leave this nil.
+	Suffix        *suffixarray.Index
+	SuffixOffsets []suffixOffsetEntry
+
+	// Trigrams maps every 3-byte substring seen in the corpus to the docs
+	// it appears in, used by RegexSearch to narrow candidate docs before
+	// running the actual regexp. Optional, like Suffix.
+	Trigrams map[string][]uint32
 }
 
 func NewIndexReader(dir string) (*IndexReader, error) {
@@ -27,235 +47,158 @@ func NewIndexReader(dir string) (*IndexReader, error) {
 		return nil, fmt.Errorf("loading docs: %w", err)
 	}
 
-	// Load Lexicon
-	if err := reader.loadLexicon(dir + "/" + models.LexiconFileName); err != nil {
-		return nil, fmt.Errorf("loading lexicon: %w", err)
+	// Fan out over every live segment
+	segNums, err := loadManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("loading segment manifest: %w", err)
 	}
 
-	// Open Index
-	f, err := os.Open(dir + "/" + models.IndexFileName)
-	if err != nil {
-		return nil, fmt.Errorf("opening index: %w", err)
+	for _, n := range segNums {
+		seg, err := openSegment(dir, n)
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("opening segment %d: %w", n, err)
+		}
+		reader.segments = append(reader.segments, seg)
+
+		for term, entry := range seg.lexicon {
+			agg := reader.Lexicon[term]
+			agg.Term = term
+			agg.DocFreq += entry.DocFreq
+			reader.Lexicon[term] = agg
+		}
 	}
 
-	// Verify Index Header
-	header := make([]byte, 13) // "DEVSCOPE_IDX" (12) + Ver(1)
-	if _, err := io.ReadFull(f, header); err != nil {
-		f.Close()
-		return nil, err
+	// Suffix index is optional - an index built before this feature existed
+	// simply won't have one, and SubstringSearch reports that cleanly.
+	if sa, offsets, err := loadSuffixIndex(dir + "/" + models.SuffixFileName); err == nil {
+		reader.Suffix = sa
+		reader.SuffixOffsets = offsets
 	}
-	if string(header[:12]) != "DEVSCOPE_IDX" {
-		f.Close()
-		return nil, fmt.Errorf("invalid index header")
+
+	// Trigram index is optional too - an index built before this feature
+	// existed simply won't have one, and RegexSearch falls back to
+	// scanning every document.
+	if tri, err := loadTrigramIndex(dir + "/" + models.TrigramFileName); err == nil {
+		reader.Trigrams = tri
 	}
 
-	reader.File = f
 	return reader, nil
 }
 
 func (r *IndexReader) Close() {
-	if r.File != nil {
-		r.File.Close()
+	for _, seg := range r.segments {
+		seg.Close()
 	}
 }
 
 func (r *IndexReader) loadDocs(path string) error {
-	// Re-implemented using internal/store code or just use store.DocReader?
-	// We didn't export NewDocReader in store properly or we defined it in docs_io.go which is in store package.
-	// So we can use store.NewDocReader.
-	// But reader.go relies on `devscope/internal/store`.
-	// I should update imports to use `devscope/internal/store`.
-	// But `store.DocReader` returns `models.DocumentRecord`.
-
-	// Wait, I can only import `devscope/internal/store` if I update imports.
-	// I'll assume I can add the import.
-	// Actually I will reimplement reading here to avoid cross-layer dependency if unnecessary,
-	// OR better, reuse `store.DocReader` which I spent time implementing.
-	// I'll update imports to include `devscope/internal/store`.
-	return r.loadDocsUsingStore(path)
-}
-
-// Helper to avoid import cycles / cleaner usage if possible. But cycle is query -> store. store -> models. models -> none. No cycle.
-// I will add the import `devscope/internal/store` in the replace block.
-
-func (r *IndexReader) loadDocsUsingStore(path string) error {
-	// I need to import store. But I can't put import mid-file.
-	// I'll manually implement for now to avoid complexity of editing imports again if I mess up.
-	// Actually, I already imported `devscope/pkg/models`.
-	// I will just reimplement the read logic since it's simple enough and I want to be sure it matches.
-	// Actually, `docs_io.go` has header verification. I should really use it.
-
-	// Let's rely on manual reading as previously implemented but with corrected headers/types.
-
-	f, err := os.Open(path)
+	dr, err := store.NewDocReader(path)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	bufReader := bufio.NewReader(f)
-
-	// Verify Header
-	const headerStr = "DEVSCOPE_DOCS"
-	header := make([]byte, len(headerStr))
-	if _, err := io.ReadFull(bufReader, header); err != nil {
-		return err
-	}
-	if string(header) != headerStr {
-		return fmt.Errorf("invalid docs header")
-	}
-	ver, err := bufReader.ReadByte()
-	if err != nil {
-		return err
-	}
-	if ver != 1 {
-		return fmt.Errorf("bad version")
-	}
+	defer dr.Close()
 
 	for {
-		// Read DocID (4)
-		var docID uint32
-		if err := binary.Read(bufReader, binary.LittleEndian, &docID); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
+		doc, err := dr.ReadNext()
+		if err == io.EOF {
+			break
 		}
-
-		// Type (1)
-		b, err := bufReader.ReadByte()
 		if err != nil {
 			return err
 		}
-		docType := models.DocType(b)
-
-		// PathLen (2)
-		var pathLen uint16
-		if err := binary.Read(bufReader, binary.LittleEndian, &pathLen); err != nil {
-			return err
-		}
-
-		fmt.Printf("Debug: DocID=%d PathLen=%d\n", docID, pathLen)
-
-		// Path
-		pathBytes := make([]byte, pathLen)
-		if _, err := io.ReadFull(bufReader, pathBytes); err != nil {
-			return err
-		}
-
-		// Timestamps (8+8)
-		var tMin, tMax int64
-		if err := binary.Read(bufReader, binary.LittleEndian, &tMin); err != nil {
-			return err
-		}
-		if err := binary.Read(bufReader, binary.LittleEndian, &tMax); err != nil {
-			return err
-		}
-
-		doc := models.DocumentRecord{
-			DocID:        docID,
-			Type:         docType,
-			Path:         string(pathBytes),
-			TimestampMin: tMin,
-			TimestampMax: tMax,
-		}
 		r.Docs[doc.DocID] = doc
 	}
 	r.TotalDocs = len(r.Docs)
+	r.AvgDocLen = dr.AvgDocLen
 	return nil
 }
 
-func (r *IndexReader) loadLexicon(path string) error {
-	f, err := os.Open(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	reader := bufio.NewReader(f)
-
-	// Verify Header "DEVSCOPE_LEX"
-	header := make([]byte, 12)
-	if _, err := io.ReadFull(reader, header); err != nil {
-		return err
+// GetPostings merges a term's posting list across every live segment: the
+// same term can appear in more than one segment, since each segment only
+// covers the documents that were in memIndex when it was flushed. DocIDs
+// are disjoint across segments - indexer.Build clears every segment from
+// the previous run before flushing the new ones that cover the complete
+// current corpus (see indexer.clearSegments), so a document only ever
+// lives in the one segment it was flushed into this run - so
+// concatenating each segment's hits and re-sorting by DocID is a correct
+// k-way merge.
+func (r *IndexReader) GetPostings(term string) ([]models.Posting, error) {
+	var merged []models.Posting
+	for _, seg := range r.segments {
+		entry, ok := seg.lexicon[term]
+		if !ok {
+			continue
+		}
+		postings, err := decodePostings(seg.mmap, entry, seg.version)
+		if err != nil {
+			return nil, fmt.Errorf("term %q: %w", term, err)
+		}
+		merged = append(merged, postings...)
 	}
-	if string(header) != "DEVSCOPE_LEX" {
-		return fmt.Errorf("bad lexicon header")
+	if merged == nil {
+		return nil, nil
 	}
-	if _, err := reader.ReadByte(); err != nil {
-		return err
-	} // Version
+	sort.Slice(merged, func(i, j int) bool { return merged[i].DocID < merged[j].DocID })
+	return merged, nil
+}
 
-	for {
-		// TermLen (2) - Updated from 1 byte
-		var termLen uint16
-		if err := binary.Read(reader, binary.LittleEndian, &termLen); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
+// GetPostingsFrom is like GetPostings but skips decoding any posting with
+// DocID < minDocID. For v2 segments this uses the term's skip list to jump
+// straight to the right neighborhood instead of varint-decoding every
+// posting before it; matchPhraseDocs uses it once earlier phrase words
+// have already narrowed the candidate set down to some minimum DocID, so
+// later words don't pay to decode postings that can't possibly match.
+func (r *IndexReader) GetPostingsFrom(term string, minDocID uint32) ([]models.Posting, error) {
+	if minDocID == 0 {
+		return r.GetPostings(term)
+	}
+
+	var merged []models.Posting
+	for _, seg := range r.segments {
+		entry, ok := seg.lexicon[term]
+		if !ok {
+			continue
 		}
 
-		termBytes := make([]byte, termLen)
-		if _, err := io.ReadFull(reader, termBytes); err != nil {
-			return err
+		var postings []models.Posting
+		var err error
+		switch seg.version {
+		case idxVersionV1:
+			postings, err = decodePostingsV1(segmentRegion(seg.mmap, entry), entry.DocFreq)
+			postings = filterByMinDocID(postings, minDocID)
+		case idxVersionV2:
+			postings, err = decodePostingsV2From(segmentRegion(seg.mmap, entry), entry.DocFreq, minDocID)
+		default:
+			err = fmt.Errorf("unsupported index version %d", seg.version)
 		}
-
-		// DocFreq(4) + Offset(8) + Len(4) = 16 bytes
-		meta := make([]byte, 16)
-		if _, err := io.ReadFull(reader, meta); err != nil {
-			return err
-		}
-
-		entry := models.LexiconEntry{
-			Term:         string(termBytes),
-			DocFreq:      binary.LittleEndian.Uint32(meta[0:4]),
-			Offset:       binary.LittleEndian.Uint64(meta[4:12]),
-			PostingCount: binary.LittleEndian.Uint32(meta[12:16]), // Actually byte length
+		if err != nil {
+			return nil, fmt.Errorf("term %q: %w", term, err)
 		}
-		r.Lexicon[entry.Term] = entry
+		merged = append(merged, postings...)
 	}
-	return nil
-}
-
-func (r *IndexReader) GetPostings(term string) ([]models.Posting, error) {
-	entry, ok := r.Lexicon[term]
-	if !ok {
+	if merged == nil {
 		return nil, nil
 	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].DocID < merged[j].DocID })
+	return merged, nil
+}
 
-	if _, err := r.File.Seek(int64(entry.Offset), 0); err != nil {
-		return nil, err
+func segmentRegion(mmap []byte, entry models.LexiconEntry) []byte {
+	start := entry.Offset
+	end := start + uint64(entry.PostingCount)
+	if end > uint64(len(mmap)) {
+		return nil
 	}
+	return mmap[start:end]
+}
 
-	// If we trusted ParsingCount as ByteLength, we could limit reading,
-	// but we can just loop DocFreq times.
-
-	postings := make([]models.Posting, 0, entry.DocFreq)
-	header := make([]byte, 13) // DocID(4)+Freq(4)+Meta(1)+PosCount(4)
-
-	for i := uint32(0); i < entry.DocFreq; i++ {
-		if _, err := io.ReadFull(r.File, header); err != nil {
-			return nil, err
-		}
-
-		p := models.Posting{
-			DocID:     binary.LittleEndian.Uint32(header[0:4]),
-			Frequency: binary.LittleEndian.Uint32(header[4:8]),
-			Meta:      header[8],
-		}
-		posCount := binary.LittleEndian.Uint32(header[9:13])
-
-		p.Positions = make([]uint32, posCount)
-		posBuf := make([]byte, 4*posCount)
-		if _, err := io.ReadFull(r.File, posBuf); err != nil {
-			return nil, err
-		}
-		for j := 0; j < int(posCount); j++ {
-			p.Positions[j] = binary.LittleEndian.Uint32(posBuf[j*4 : j*4+4])
+func filterByMinDocID(postings []models.Posting, minDocID uint32) []models.Posting {
+	kept := postings[:0]
+	for _, p := range postings {
+		if p.DocID >= minDocID {
+			kept = append(kept, p)
 		}
-
-		postings = append(postings, p)
 	}
-
-	return postings, nil
+	return kept
 }
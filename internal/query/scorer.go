@@ -0,0 +1,131 @@
+package query
+
+import "math"
+
+// bm25IDF computes a term's inverse document frequency using the standard
+// Okapi BM25 smoothed form, log((N-df+0.5)/(df+0.5) + 1), rather than the
+// plain log(N/(df+1)) ratio: the BM25 form stays non-negative for every
+// df in [0, N] (it only approaches 0 as df -> N), where the plain ratio
+// hits 0 at df = N-1 and goes negative once a term appears in every
+// document - trivially true on small/single-file indexes - producing
+// nonsensical negative relevance scores.
+func bm25IDF(totalDocs int, docFreq uint32) float64 {
+	n := float64(totalDocs)
+	df := float64(docFreq)
+	return math.Log((n-df+0.5)/(df+0.5) + 1)
+}
+
+// Field-weight meta bits. These mirror indexer.MetaInFileName et al in
+// internal/indexer/tokenizer.go; query doesn't import indexer to avoid a
+// cycle, so the bit layout is duplicated here and must stay in sync.
+const (
+	metaInFileName     = 1 << 0
+	metaInFunctionName = 1 << 1
+	metaLogLevelError  = 1 << 2
+	metaLogLevelWarn   = 1 << 3
+	metaInTypeName     = 1 << 4
+	metaInMethodName   = 1 << 5
+	metaInImport       = 1 << 6
+	metaInComment      = 1 << 7
+)
+
+// FieldWeights are multipliers applied to a term's base score depending on
+// which fields of the document it matched in. A weight of 1.0 is a no-op;
+// values above 1.0 rank that field's hits higher.
+type FieldWeights struct {
+	FileName     float64
+	FunctionName float64
+	ErrorLog     float64
+}
+
+// DefaultFieldWeights returns the weights Search uses when a caller doesn't
+// configure its own, replacing the old +5.0/+3.0 magic-number bonuses for
+// filename/function-name hits with a boost for error-log lines.
+func DefaultFieldWeights() FieldWeights {
+	return FieldWeights{
+		FileName:     3.0,
+		FunctionName: 2.0,
+		ErrorLog:     1.5,
+	}
+}
+
+// Multiplier combines every field weight that applies to a posting's meta
+// bitmask. Bits stack multiplicatively: a term that's both in the filename
+// and inside an error log line gets both boosts.
+func (w FieldWeights) Multiplier(meta uint8) float64 {
+	m := 1.0
+	if meta&metaInFileName != 0 {
+		m *= w.FileName
+	}
+	if meta&metaInFunctionName != 0 {
+		m *= w.FunctionName
+	}
+	if meta&metaLogLevelError != 0 {
+		m *= w.ErrorLog
+	}
+	return m
+}
+
+// Scorer computes a single term's contribution to a document's relevance
+// score, given that term's idf, its frequency in the document, and the
+// document's length relative to the corpus average. Search sums Score
+// across every matching term (and every synthetic phrase-term); a single
+// term's per-posting Score is multiplied by FieldWeight(posting meta)
+// before it's added in, while a phrase-term's Score is added unweighted.
+type Scorer interface {
+	Score(tf, idf float64, docLen uint32, avgDocLen float64) float64
+	FieldWeight(meta uint8) float64
+}
+
+// BM25Scorer implements Okapi BM25:
+//
+//	score = idf(t) * (tf*(k1+1)) / (tf + k1*(1 - b + b*|d|/avgdl))
+//
+// K1 controls term-frequency saturation and B controls how strongly
+// document length is normalized against the corpus average; 1.2/0.75 are
+// the usual defaults.
+type BM25Scorer struct {
+	K1      float64
+	B       float64
+	Weights FieldWeights
+}
+
+// NewBM25Scorer returns a BM25Scorer with the standard K1/B defaults and
+// DefaultFieldWeights.
+func NewBM25Scorer() *BM25Scorer {
+	return &BM25Scorer{K1: 1.2, B: 0.75, Weights: DefaultFieldWeights()}
+}
+
+func (s *BM25Scorer) Score(tf, idf float64, docLen uint32, avgDocLen float64) float64 {
+	if avgDocLen == 0 {
+		avgDocLen = float64(docLen)
+	}
+	if avgDocLen == 0 {
+		avgDocLen = 1
+	}
+	norm := 1 - s.B + s.B*(float64(docLen)/avgDocLen)
+	return idf * (tf * (s.K1 + 1)) / (tf + s.K1*norm)
+}
+
+func (s *BM25Scorer) FieldWeight(meta uint8) float64 {
+	return s.Weights.Multiplier(meta)
+}
+
+// TFIDFScorer is the legacy tf*idf scorer kept for comparison/rollback; it
+// does no document-length normalization.
+type TFIDFScorer struct {
+	Weights FieldWeights
+}
+
+// NewTFIDFScorer returns a TFIDFScorer with DefaultFieldWeights.
+func NewTFIDFScorer() *TFIDFScorer {
+	return &TFIDFScorer{Weights: DefaultFieldWeights()}
+}
+
+func (s *TFIDFScorer) Score(tf, idf float64, docLen uint32, avgDocLen float64) float64 {
+	return tf * idf
+}
+
+func (s *TFIDFScorer) FieldWeight(meta uint8) float64 {
+	return s.Weights.Multiplier(meta)
+}
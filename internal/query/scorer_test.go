@@ -0,0 +1,37 @@
+package query
+
+import "testing"
+
+// TestBM25IDFNonNegative covers the bug a plain log(N/(df+1)) ratio has:
+// it goes negative once a term appears in every document, which is the
+// common case on small/single-file indexes. The Okapi form
+// must stay non-negative for every df in [0, N].
+func TestBM25IDFNonNegative(t *testing.T) {
+	cases := []struct {
+		totalDocs int
+		docFreq   uint32
+	}{
+		{1, 1}, // the only doc in a 1-file index
+		{2, 2}, // every doc in a 2-file index
+		{10, 10},
+		{10, 1},
+		{10, 0},
+	}
+
+	for _, c := range cases {
+		idf := bm25IDF(c.totalDocs, c.docFreq)
+		if idf < 0 {
+			t.Errorf("bm25IDF(%d, %d) = %v, want >= 0", c.totalDocs, c.docFreq, idf)
+		}
+	}
+}
+
+// TestBM25IDFDecreasesWithDocFreq checks the basic IDF shape: rarer terms
+// score higher than common ones.
+func TestBM25IDFDecreasesWithDocFreq(t *testing.T) {
+	rare := bm25IDF(100, 1)
+	common := bm25IDF(100, 50)
+	if !(rare > common) {
+		t.Errorf("expected rare-term idf (%v) > common-term idf (%v)", rare, common)
+	}
+}
@@ -3,10 +3,12 @@ package query
 import (
 	"bufio"
 	"devscope/pkg/models"
-	"math"
 	"os"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type SearchResult struct {
@@ -19,13 +21,32 @@ type SearchResult struct {
 }
 
 // main search function that coordinates everything
-func Search(idx *IndexReader, queryString string) ([]SearchResult, error) {
-	terms, phrases, levelFilter, extFilter := parseQuery(queryString)
+func Search(idx *IndexReader, queryString string, scorer Scorer) ([]SearchResult, error) {
+	terms, phrases, levelFilter, extFilter, kindFilter, pathFilter, afterTS, beforeTS := parseQuery(queryString)
 
 	if len(terms) == 0 && len(phrases) == 0 {
 		return nil, nil
 	}
 
+	var pathDocs map[uint32]bool
+	if pathFilter != "" {
+		pathPostings, err := idx.GetPostings(pathFilter)
+		if err != nil {
+			return nil, err
+		}
+		if pathPostings == nil {
+			return nil, nil
+		}
+		pathDocs = make(map[uint32]bool, len(pathPostings))
+		for _, p := range pathPostings {
+			// Only path/filename terms count; the same word appearing in
+			// a file's content doesn't put that file under the path.
+			if p.Meta&metaInFileName != 0 {
+				pathDocs[p.DocID] = true
+			}
+		}
+	}
+
 	scores := make(map[uint32]float64)
 	totalFreqs := make(map[uint32]uint32) // tracks total occurrences of terms/phrases
 	docMatches := make(map[uint32]int)    // tracks how many terms/phrases matched per doc
@@ -42,37 +63,28 @@ func Search(idx *IndexReader, queryString string) ([]SearchResult, error) {
 		}
 
 		lexEntry := idx.Lexicon[term]
-		idf := math.Log(float64(idx.TotalDocs) / (float64(lexEntry.DocFreq) + 1))
+		idf := bm25IDF(idx.TotalDocs, lexEntry.DocFreq)
 
-		processPostings(postings, idx.Docs, idf, scores, docMatches, totalFreqs, levelFilter, extFilter)
+		processPostings(postings, idx.Docs, idf, idx.AvgDocLen, scorer, scores, docMatches, totalFreqs, levelFilter, extFilter, kindFilter, pathDocs, afterTS, beforeTS)
 	}
 
-	// 2. Process Phrases
+	// 2. Process Phrases. A phrase is scored as one synthetic term (summed
+	// idf across its words, tf = the matched adjacency count) rather than
+	// adding a flat bonus on top of the single-term scores, so it can't
+	// double-count against the words that make it up.
 	for _, phrase := range phrases {
-		var phrasePostings [][]models.Posting
-		for _, word := range phrase {
-			p, err := idx.GetPostings(word)
-			if err != nil {
-				return nil, err
-			}
-			if p == nil {
-				phrasePostings = nil
-				break
-			}
-			phrasePostings = append(phrasePostings, p)
+		matchedCounts, err := matchPhraseDocs(idx, phrase, levelFilter, kindFilter)
+		if err != nil {
+			return nil, err
 		}
-
-		if phrasePostings == nil {
+		if matchedCounts == nil {
 			continue
 		}
 
-		// intersection logic now returns counts
-		matchedCounts := matchPhraseDocs(phrasePostings)
-
 		var phraseIdf float64
 		for _, word := range phrase {
 			lexEntry := idx.Lexicon[word]
-			phraseIdf += math.Log(float64(idx.TotalDocs) / (float64(lexEntry.DocFreq) + 1))
+			phraseIdf += bm25IDF(idx.TotalDocs, lexEntry.DocFreq)
 		}
 
 		for docID, count := range matchedCounts {
@@ -80,10 +92,16 @@ func Search(idx *IndexReader, queryString string) ([]SearchResult, error) {
 			if extFilter != "" && !strings.HasSuffix(strings.ToLower(doc.Path), extFilter) {
 				continue
 			}
+			if pathDocs != nil && !pathDocs[docID] {
+				continue
+			}
+			if !inTimeWindow(doc, afterTS, beforeTS) {
+				continue
+			}
 
 			// use actual phrase count for scoring
 			tf := float64(count)
-			score := tf * phraseIdf * 2.0 // bonus for phrase
+			score := scorer.Score(tf, phraseIdf, doc.TokenCount, idx.AvgDocLen)
 
 			scores[docID] += score
 			totalFreqs[docID] += count
@@ -126,12 +144,19 @@ func Search(idx *IndexReader, queryString string) ([]SearchResult, error) {
 
 	for i := range results {
 		results[i].Snippet, results[i].LineNum = getSnippet(results[i].Path, displayTerm)
+		if results[i].Snippet == "" {
+			// The match came from a path/filename term (e.g. a directory
+			// name) that never appears verbatim in the file's content, so
+			// getSnippet found no line to show. Fall back to a preview of
+			// the file itself.
+			results[i].Snippet, results[i].LineNum = firstLines(results[i].Path, 3)
+		}
 	}
 
 	return results, nil
 }
 
-func processPostings(postings []models.Posting, docs map[uint32]models.DocumentRecord, idf float64, scores map[uint32]float64, docMatches map[uint32]int, totalFreqs map[uint32]uint32, levelFilter, extFilter string) {
+func processPostings(postings []models.Posting, docs map[uint32]models.DocumentRecord, idf, avgDocLen float64, scorer Scorer, scores map[uint32]float64, docMatches map[uint32]int, totalFreqs map[uint32]uint32, levelFilter, extFilter, kindFilter string, pathDocs map[uint32]bool, afterTS, beforeTS int64) {
 	for _, p := range postings {
 		doc := docs[p.DocID]
 
@@ -139,47 +164,130 @@ func processPostings(postings []models.Posting, docs map[uint32]models.DocumentR
 			continue
 		}
 
-		if levelFilter == "ERROR" {
-			if (p.Meta & (1 << 2)) == 0 {
-				continue
-			}
-		} else if levelFilter == "WARN" {
-			if (p.Meta & (1 << 3)) == 0 {
-				continue
-			}
+		if pathDocs != nil && !pathDocs[p.DocID] {
+			continue
 		}
 
-		tf := float64(p.Frequency)
-		score := tf * idf
-
-		if (p.Meta & (1 << 0)) != 0 {
-			score += 5.0
+		if !inTimeWindow(doc, afterTS, beforeTS) {
+			continue
 		}
-		if (p.Meta & (1 << 1)) != 0 {
-			score += 3.0
+
+		if !postingMetaMatches(p.Meta, levelFilter, kindFilter) {
+			continue
 		}
 
+		tf := float64(p.Frequency)
+		score := scorer.Score(tf, idf, doc.TokenCount, avgDocLen) * scorer.FieldWeight(p.Meta)
+
 		scores[p.DocID] += score
 		totalFreqs[p.DocID] += p.Frequency
 		docMatches[p.DocID]++
 	}
 }
 
-// Brain of our Phrase Matching Algo
-func matchPhraseDocs(postingsList [][]models.Posting) map[uint32]uint32 {
-	candidates := make(map[uint32][]uint32) // docID -> positions of match chain
+// postingMetaMatches reports whether a posting's Meta bits satisfy the
+// level:/kind: filters (an empty filter means no constraint on that axis).
+// Shared by processPostings (single terms) and matchPhraseDocs (phrase
+// words) so both honor the same filter contract instead of phrase queries
+// silently skipping it.
+func postingMetaMatches(meta uint8, levelFilter, kindFilter string) bool {
+	if levelFilter == "ERROR" && (meta&metaLogLevelError) == 0 {
+		return false
+	}
+	if levelFilter == "WARN" && (meta&(1<<3)) == 0 {
+		return false
+	}
+	if kindFilter != "" && (meta&kindMetaBit(kindFilter)) == 0 {
+		return false
+	}
+	return true
+}
+
+// kindMetaBit maps a kind: filter value to the Meta bit a matching posting
+// must carry. An unrecognized kind matches nothing rather than everything,
+// so a typo'd filter doesn't silently degrade into an unfiltered search.
+func kindMetaBit(kind string) uint8 {
+	switch kind {
+	case "func", "function":
+		return metaInFunctionName
+	case "method":
+		return metaInMethodName
+	case "type":
+		return metaInTypeName
+	case "import":
+		return metaInImport
+	case "comment":
+		return metaInComment
+	default:
+		return 0
+	}
+}
+
+// inTimeWindow reports whether doc's [TimestampMin, TimestampMax] overlaps
+// the [after, before] query window (0 meaning "no bound on that side"). A
+// doc with no timestamp data at all (e.g. a code file) can't satisfy a time
+// filter, so it's excluded the moment either bound is set.
+func inTimeWindow(doc models.DocumentRecord, after, before int64) bool {
+	if after == 0 && before == 0 {
+		return true
+	}
+	if doc.TimestampMin == 0 && doc.TimestampMax == 0 {
+		return false
+	}
+	if after != 0 && doc.TimestampMax < after {
+		return false
+	}
+	if before != 0 && doc.TimestampMin > before {
+		return false
+	}
+	return true
+}
 
-	firstList := postingsList[0]
+// Brain of our Phrase Matching Algo. Each word after the first is fetched
+// with GetPostingsFrom(word, minCandidateDocID) instead of a full
+// GetPostings, so once earlier words have narrowed the candidates down, a
+// v2 segment's skip list lets later words' posting lists skip straight to
+// that neighborhood instead of varint-decoding every doc that's already
+// been ruled out. levelFilter/kindFilter are applied to every word's
+// posting via postingMetaMatches, the same Meta checks processPostings
+// applies to single terms, so a phrase honors level:/kind: too instead of
+// only the ext:/path:/time-window filters applied afterward in Search.
+func matchPhraseDocs(idx *IndexReader, phrase []string, levelFilter, kindFilter string) (map[uint32]uint32, error) {
+	firstList, err := idx.GetPostings(phrase[0])
+	if err != nil {
+		return nil, err
+	}
+	if firstList == nil {
+		return nil, nil
+	}
+
+	candidates := make(map[uint32][]uint32) // docID -> positions of match chain
 	for _, p := range firstList {
+		if !postingMetaMatches(p.Meta, levelFilter, kindFilter) {
+			continue
+		}
 		candidates[p.DocID] = p.Positions
 	}
 
-	// Intersect
-	for i := 1; i < len(postingsList); i++ {
-		nextCandidates := make(map[uint32][]uint32)
-		currentWordPostings := postingsList[i]
+	for i := 1; i < len(phrase); i++ {
+		if len(candidates) == 0 {
+			break
+		}
 
+		minDocID := minDocIDKey(candidates)
+		currentWordPostings, err := idx.GetPostingsFrom(phrase[i], minDocID)
+		if err != nil {
+			return nil, err
+		}
+		if currentWordPostings == nil {
+			return nil, nil
+		}
+
+		nextCandidates := make(map[uint32][]uint32)
 		for _, p := range currentWordPostings {
+			if !postingMetaMatches(p.Meta, levelFilter, kindFilter) {
+				continue
+			}
 			prevPositions, ok := candidates[p.DocID] // checks if docID is in candidates
 			if !ok {
 				continue
@@ -201,9 +309,6 @@ func matchPhraseDocs(postingsList [][]models.Posting) map[uint32]uint32 {
 			}
 		}
 		candidates = nextCandidates
-		if len(candidates) == 0 {
-			break
-		}
 	}
 
 	// convert valid candidates to frequency counts
@@ -211,10 +316,34 @@ func matchPhraseDocs(postingsList [][]models.Posting) map[uint32]uint32 {
 	for id, positions := range candidates {
 		finalCounts[id] = uint32(len(positions))
 	}
-	return finalCounts
+	return finalCounts, nil
 }
 
-func parseQuery(q string) (terms []string, phrases [][]string, level, ext string) {
+// minDocIDKey returns the smallest DocID key in candidates, used to bound
+// how far back the next phrase word's posting list needs to be decoded
+// from.
+func minDocIDKey(candidates map[uint32][]uint32) uint32 {
+	min := ^uint32(0)
+	for id := range candidates {
+		if id < min {
+			min = id
+		}
+	}
+	return min
+}
+
+// parseQuery splits a query string into plain terms, quoted phrases, and
+// the level:/ext:/kind:/path:/after:/before:/within: filter prefixes
+// (since:/until: are accepted as aliases for after:/before:, matching the
+// --since/--until CLI flags). after and before are unix seconds, 0 meaning
+// unset; within:<duration> is shorthand for "after: now minus duration".
+// kind: restricts single-term and phrase matches to a symbol kind (func,
+// method, type, import, comment) - see kindMetaBit. path: (aliased as file:)
+// restricts results to docs whose indexer.pathHierarchyTerms include the
+// given value - a directory prefix ("path:internal/query"), a bare
+// filename ("file:searcher.go"), or a stem ("file:searcher") all work, the
+// same way ext:/level: narrow by other posting metadata.
+func parseQuery(q string) (terms []string, phrases [][]string, level, ext, kind, path string, after, before int64) {
 	// manual parsing loop
 	var buffer strings.Builder
 	inQuote := false
@@ -228,6 +357,32 @@ func parseQuery(q string) (terms []string, phrases [][]string, level, ext string
 				level = strings.ToUpper(strings.TrimPrefix(s, "level:"))
 			} else if strings.HasPrefix(s, "ext:") {
 				ext = strings.ToLower(strings.TrimPrefix(s, "ext:"))
+			} else if strings.HasPrefix(s, "kind:") {
+				kind = strings.ToLower(strings.TrimPrefix(s, "kind:"))
+			} else if strings.HasPrefix(s, "path:") {
+				path = strings.ToLower(strings.TrimPrefix(s, "path:"))
+			} else if strings.HasPrefix(s, "file:") {
+				path = strings.ToLower(strings.TrimPrefix(s, "file:"))
+			} else if strings.HasPrefix(s, "after:") {
+				if ts, ok := parseQueryTimestamp(strings.TrimPrefix(s, "after:")); ok {
+					after = ts
+				}
+			} else if strings.HasPrefix(s, "since:") {
+				if ts, ok := parseQueryTimestamp(strings.TrimPrefix(s, "since:")); ok {
+					after = ts
+				}
+			} else if strings.HasPrefix(s, "before:") {
+				if ts, ok := parseQueryTimestamp(strings.TrimPrefix(s, "before:")); ok {
+					before = ts
+				}
+			} else if strings.HasPrefix(s, "until:") {
+				if ts, ok := parseQueryTimestamp(strings.TrimPrefix(s, "until:")); ok {
+					before = ts
+				}
+			} else if strings.HasPrefix(s, "within:") {
+				if d, err := time.ParseDuration(strings.TrimPrefix(s, "within:")); err == nil {
+					after = time.Now().Add(-d).Unix()
+				}
 			} else {
 				terms = append(terms, strings.ToLower(s))
 			}
@@ -263,6 +418,42 @@ func parseQuery(q string) (terms []string, phrases [][]string, level, ext string
 	return
 }
 
+var reQueryEpochMillis = regexp.MustCompile(`^\d{13}$`)
+
+// parseQueryTimestamp parses an after:/before:/since:/until: value in
+// whichever of these a user is likely to type: a bare date, an ISO
+// datetime with either separator, RFC3339 (with or without fractional
+// seconds/timezone), syslog's year-less "Jan _2 15:04:05", or a raw
+// epoch-millis integer.
+func parseQueryTimestamp(s string) (int64, bool) {
+	if reQueryEpochMillis.MatchString(s) {
+		if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return ms / 1000, true
+		}
+	}
+
+	layouts := []string{
+		"2006-01-02",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		time.RFC3339,
+		time.RFC3339Nano,
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Unix(), true
+		}
+	}
+
+	if t, err := time.Parse("Jan _2 15:04:05", s); err == nil {
+		now := time.Now()
+		t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+		return t.Unix(), true
+	}
+
+	return 0, false
+}
+
 // finds the line with the term to show context
 func getSnippet(path string, term string) (string, uint32) {
 	f, err := os.Open(path)
@@ -287,3 +478,30 @@ func getSnippet(path string, term string) (string, uint32) {
 	}
 	return "", 0
 }
+
+// firstLines previews a file by joining its first n non-empty lines, for
+// results whose match is a filename/path term rather than file content.
+func firstLines(path string, n int) (string, uint32) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var preview []string
+	for len(preview) < n && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			preview = append(preview, line)
+		}
+	}
+	if len(preview) == 0 {
+		return "", 0
+	}
+	snippet := strings.Join(preview, " / ")
+	if len(snippet) > 200 {
+		snippet = snippet[:200] + "..."
+	}
+	return snippet, 1
+}
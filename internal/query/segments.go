@@ -0,0 +1,289 @@
+package query
+
+import (
+	"bufio"
+	"devscope/pkg/models"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func segmentIdxName(n int) string { return fmt.Sprintf("seg-%06d.idx", n) }
+func segmentLexName(n int) string { return fmt.Sprintf("seg-%06d.lex", n) }
+
+// idxVersionV1/idxVersionV2 mirror indexer.idxVersionV1/idxVersionV2 in
+// internal/indexer/builder.go; query doesn't import indexer to avoid a
+// cycle, so the version byte meaning is duplicated here and must stay in
+// sync.
+const (
+	idxVersionV1 = 1
+	idxVersionV2 = 2
+
+	// skipInterval mirrors indexer.skipInterval: how many postings separate
+	// each (DocID, offset) entry in a v2 posting list's skip table.
+	skipInterval = 128
+)
+
+// segmentReader holds one immutable on-disk segment: its postings mmap'd
+// read-only, and its term -> offset lexicon loaded in full (lexicons are
+// small relative to postings, so there's no need to mmap those too).
+type segmentReader struct {
+	file    *os.File
+	mmap    []byte
+	version byte
+	lexicon map[string]models.LexiconEntry
+}
+
+func openSegment(outDir string, n int) (*segmentReader, error) {
+	lex, err := loadSegmentLexicon(filepath.Join(outDir, segmentLexName(n)))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(outDir, segmentIdxName(n)))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := mmapFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if len(data) < 13 || string(data[:12]) != "DEVSCOPE_IDX" {
+		munmapFile(data)
+		f.Close()
+		return nil, fmt.Errorf("invalid segment index header")
+	}
+
+	return &segmentReader{file: f, mmap: data, version: data[12], lexicon: lex}, nil
+}
+
+func (s *segmentReader) Close() {
+	if s.mmap != nil {
+		munmapFile(s.mmap)
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+}
+
+func loadSegmentLexicon(path string) (map[string]models.LexiconEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header) != "DEVSCOPE_LEX" {
+		return nil, fmt.Errorf("bad lexicon header in %s", path)
+	}
+	if _, err := r.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]models.LexiconEntry)
+	for {
+		var termLen uint16
+		if err := binary.Read(r, binary.LittleEndian, &termLen); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		termBytes := make([]byte, termLen)
+		if _, err := io.ReadFull(r, termBytes); err != nil {
+			return nil, err
+		}
+		meta := make([]byte, 16)
+		if _, err := io.ReadFull(r, meta); err != nil {
+			return nil, err
+		}
+		entries[string(termBytes)] = models.LexiconEntry{
+			Term:         string(termBytes),
+			DocFreq:      binary.LittleEndian.Uint32(meta[0:4]),
+			Offset:       binary.LittleEndian.Uint64(meta[4:12]),
+			PostingCount: binary.LittleEndian.Uint32(meta[12:16]), // byte length
+		}
+	}
+	return entries, nil
+}
+
+// decodePostings decodes a single term's full posting list out of a
+// segment's mmap'd region, bounded in O(1) by entry.PostingCount (a byte
+// length). version dispatches between the old fixed-width format and the
+// delta+varint one (see internal/indexer/builder.go's encodePostingsV2).
+func decodePostings(mmap []byte, entry models.LexiconEntry, version byte) ([]models.Posting, error) {
+	start := entry.Offset
+	end := start + uint64(entry.PostingCount)
+	if end > uint64(len(mmap)) {
+		return nil, fmt.Errorf("posting list out of bounds (offset=%d len=%d mmap=%d)", start, entry.PostingCount, len(mmap))
+	}
+	region := mmap[start:end]
+
+	switch version {
+	case idxVersionV1:
+		return decodePostingsV1(region, entry.DocFreq)
+	case idxVersionV2:
+		return decodePostingsV2From(region, entry.DocFreq, 0)
+	default:
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+}
+
+// decodePostingsV1 decodes the original fixed-width posting format: per
+// posting, DocID(4) + Frequency(4) + Meta(1) + PosCount(4) + Positions(4
+// each), back to back with no skip list.
+func decodePostingsV1(region []byte, docFreq uint32) ([]models.Posting, error) {
+	postings := make([]models.Posting, 0, docFreq)
+	off := 0
+	for i := uint32(0); i < docFreq; i++ {
+		if off+13 > len(region) {
+			return nil, fmt.Errorf("posting list truncated")
+		}
+
+		p := models.Posting{
+			DocID:     binary.LittleEndian.Uint32(region[off : off+4]),
+			Frequency: binary.LittleEndian.Uint32(region[off+4 : off+8]),
+			Meta:      region[off+8],
+		}
+		posCount := binary.LittleEndian.Uint32(region[off+9 : off+13])
+		off += 13
+
+		posEnd := off + 4*int(posCount)
+		if posEnd > len(region) {
+			return nil, fmt.Errorf("posting list truncated (positions)")
+		}
+
+		p.Positions = make([]uint32, posCount)
+		for j := uint32(0); j < posCount; j++ {
+			p.Positions[j] = binary.LittleEndian.Uint32(region[off : off+4])
+			off += 4
+		}
+
+		postings = append(postings, p)
+	}
+	return postings, nil
+}
+
+// decodePostingsV2From decodes a v2 posting list, using the skip list to
+// jump straight to the first posting with DocID >= minDocID instead of
+// varint-decoding every posting before it. Passing minDocID=0 decodes the
+// whole list from the start.
+func decodePostingsV2From(region []byte, docFreq uint32, minDocID uint32) ([]models.Posting, error) {
+	if len(region) < 4 {
+		return nil, fmt.Errorf("posting list truncated (skip count)")
+	}
+	skipCount := binary.LittleEndian.Uint32(region[0:4])
+	blobStart := 4 + int(skipCount)*8
+	if blobStart > len(region) {
+		return nil, fmt.Errorf("posting list truncated (skip table)")
+	}
+
+	off, skipped := blobStart, uint32(0)
+	knownDocID, haveKnown := uint32(0), false
+	for i := 0; i < int(skipCount); i++ {
+		entOff := 4 + i*8
+		docID := binary.LittleEndian.Uint32(region[entOff : entOff+4])
+		if docID > minDocID {
+			break
+		}
+		off = blobStart + int(binary.LittleEndian.Uint32(region[entOff+4:entOff+8]))
+		knownDocID, haveKnown = docID, true
+		skipped = uint32(i) * skipInterval
+	}
+
+	postings := make([]models.Posting, 0, docFreq-skipped)
+	docID := uint32(0)
+	for i := skipped; i < docFreq; i++ {
+		docDelta, n := binary.Uvarint(region[off:])
+		if n <= 0 {
+			return nil, fmt.Errorf("posting list corrupt (docid varint)")
+		}
+		off += n
+		// The skip table gives the absolute DocID of the posting it points
+		// at directly; that posting's own on-disk delta is still relative
+		// to whatever posting preceded it in the full sequence, so it must
+		// be consumed (to advance off) but not added in - only subsequent
+		// postings in this run accumulate their deltas normally.
+		if i == skipped && haveKnown {
+			docID = knownDocID
+		} else {
+			docID += uint32(docDelta)
+		}
+
+		freq, n := binary.Uvarint(region[off:])
+		if n <= 0 {
+			return nil, fmt.Errorf("posting list corrupt (freq varint)")
+		}
+		off += n
+
+		if off >= len(region) {
+			return nil, fmt.Errorf("posting list truncated (meta)")
+		}
+		meta := region[off]
+		off++
+
+		posCount, n := binary.Uvarint(region[off:])
+		if n <= 0 {
+			return nil, fmt.Errorf("posting list corrupt (poscount varint)")
+		}
+		off += n
+
+		positions := make([]uint32, posCount)
+		prevPos := uint32(0)
+		for j := range positions {
+			delta, n := binary.Uvarint(region[off:])
+			if n <= 0 {
+				return nil, fmt.Errorf("posting list corrupt (position varint)")
+			}
+			off += n
+			prevPos += uint32(delta)
+			positions[j] = prevPos
+		}
+
+		if docID >= minDocID {
+			postings = append(postings, models.Posting{
+				DocID:     docID,
+				Frequency: uint32(freq),
+				Meta:      meta,
+				Positions: positions,
+			})
+		}
+	}
+	return postings, nil
+}
+
+// loadManifest returns the live segment numbers for dir, oldest first, as
+// written by indexer.IndexBuilder. A missing manifest means a corpus with
+// no indexed files at all (e.g. every file was deleted then reindexed) -
+// Build's final flushSegment is a no-op when memIndex is empty, so no
+// manifest ever gets written - and is treated the same as an empty one
+// rather than an error.
+func loadManifest(dir string) ([]int, error) {
+	f, err := os.Open(filepath.Join(dir, models.SegmentManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var segs []int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var n int
+		if _, err := fmt.Sscanf(scanner.Text(), "%d", &n); err == nil {
+			segs = append(segs, n)
+		}
+	}
+	return segs, scanner.Err()
+}
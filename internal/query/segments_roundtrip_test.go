@@ -0,0 +1,123 @@
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"devscope/pkg/models"
+)
+
+// encodeV2TestRegion mirrors indexer.encodePostingsV2 + the layout
+// writeSegmentFiles lays postings out in on disk: a delta+varint blob with
+// a skip-list entry (absolute DocID, byte offset) every skipInterval
+// postings, prefixed by the skip count and the skip table itself. It's
+// reimplemented here rather than imported to avoid query depending on
+// indexer (see idxVersionV1/idxVersionV2's doc comment).
+func encodeV2TestRegion(postings []models.Posting) []byte {
+	var blob bytes.Buffer
+	var skip [][2]uint32 // (docID, offset)
+	varintBuf := make([]byte, binary.MaxVarintLen64)
+
+	prevDocID := uint32(0)
+	for i, p := range postings {
+		if i%skipInterval == 0 {
+			skip = append(skip, [2]uint32{p.DocID, uint32(blob.Len())})
+		}
+
+		n := binary.PutUvarint(varintBuf, uint64(p.DocID-prevDocID))
+		blob.Write(varintBuf[:n])
+		prevDocID = p.DocID
+
+		n = binary.PutUvarint(varintBuf, uint64(p.Frequency))
+		blob.Write(varintBuf[:n])
+
+		blob.WriteByte(p.Meta)
+
+		n = binary.PutUvarint(varintBuf, uint64(len(p.Positions)))
+		blob.Write(varintBuf[:n])
+
+		prevPos := uint32(0)
+		for _, pos := range p.Positions {
+			n = binary.PutUvarint(varintBuf, uint64(pos-prevPos))
+			blob.Write(varintBuf[:n])
+			prevPos = pos
+		}
+	}
+
+	var region bytes.Buffer
+	var countBuf [4]byte
+	binary.LittleEndian.PutUint32(countBuf[:], uint32(len(skip)))
+	region.Write(countBuf[:])
+	var entryBuf [8]byte
+	for _, se := range skip {
+		binary.LittleEndian.PutUint32(entryBuf[0:4], se[0])
+		binary.LittleEndian.PutUint32(entryBuf[4:8], se[1])
+		region.Write(entryBuf[:])
+	}
+	region.Write(blob.Bytes())
+	return region.Bytes()
+}
+
+// TestDecodePostingsV2FromSkipSeek covers chunk0-7's skip-list-seek path -
+// the one genuinely new piece of logic the delta+varint rewrite
+// introduced - with a posting list long enough to span several skip table
+// entries (skipInterval=128), seeking to minDocID targets on either side
+// of a skip boundary as well as past the end of the list.
+func TestDecodePostingsV2FromSkipSeek(t *testing.T) {
+	const n = 300
+	postings := make([]models.Posting, n)
+	for i := 0; i < n; i++ {
+		docID := uint32(i * 3)
+		postings[i] = models.Posting{
+			DocID:     docID,
+			Frequency: 1,
+			Meta:      0,
+			Positions: []uint32{uint32(i)},
+		}
+	}
+	region := encodeV2TestRegion(postings)
+
+	cases := []struct {
+		name     string
+		minDocID uint32
+	}{
+		{"fromStart", 0},
+		{"midFirstSkipBlock", postings[5].DocID},
+		{"exactlyOnSecondSkipEntry", postings[skipInterval].DocID},
+		{"justPastSecondSkipEntry", postings[skipInterval].DocID + 1},
+		{"lastSkipBlock", postings[2*skipInterval+10].DocID},
+		{"pastEveryPosting", postings[n-1].DocID + 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodePostingsV2From(region, uint32(n), tc.minDocID)
+			if err != nil {
+				t.Fatalf("decodePostingsV2From(minDocID=%d): %v", tc.minDocID, err)
+			}
+
+			var want []models.Posting
+			for _, p := range postings {
+				if p.DocID >= tc.minDocID {
+					want = append(want, p)
+				}
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("minDocID=%d: got %d postings, want %d", tc.minDocID, len(got), len(want))
+			}
+			for i := range want {
+				if got[i].DocID != want[i].DocID {
+					t.Fatalf("minDocID=%d: postings[%d].DocID = %d, want %d", tc.minDocID, i, got[i].DocID, want[i].DocID)
+				}
+				if got[i].Frequency != want[i].Frequency {
+					t.Fatalf("minDocID=%d: postings[%d].Frequency = %d, want %d", tc.minDocID, i, got[i].Frequency, want[i].Frequency)
+				}
+				if len(got[i].Positions) != 1 || got[i].Positions[0] != want[i].Positions[0] {
+					t.Fatalf("minDocID=%d: postings[%d].Positions = %v, want %v", tc.minDocID, i, got[i].Positions, want[i].Positions)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package query
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"index/suffixarray"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// suffixOffsetEntry maps a run of bytes in the concatenated suffix buffer
+// back to the document and in-file byte offset it came from. Mirrors
+// indexer.suffixDocOffset, the type that wrote suffix.bin.
+type suffixOffsetEntry struct {
+	DocID  uint32
+	Start  int64
+	Length int64
+}
+
+// loadSuffixIndex reads suffix.bin: our own offset table followed by a
+// serialized suffixarray.Index (which carries the original concatenated
+// buffer along with the sorted suffix array).
+func loadSuffixIndex(path string) (*suffixarray.Index, []suffixOffsetEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	if string(header) != "DEVSCOPE_SUF" {
+		return nil, nil, fmt.Errorf("bad suffix header")
+	}
+	if _, err := r.ReadByte(); err != nil {
+		return nil, nil, err
+	}
+
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, nil, err
+	}
+
+	offsets := make([]suffixOffsetEntry, n)
+	for i := range offsets {
+		if err := binary.Read(r, binary.LittleEndian, &offsets[i].DocID); err != nil {
+			return nil, nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &offsets[i].Start); err != nil {
+			return nil, nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &offsets[i].Length); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sa := new(suffixarray.Index)
+	if err := sa.Read(r); err != nil {
+		return nil, nil, err
+	}
+
+	return sa, offsets, nil
+}
+
+// resolveSuffixOffset finds which document a byte position in the suffix
+// buffer belongs to. suffixDocOffsets is written in the order documents
+// were appended to the buffer, i.e. sorted by Start, so this is a binary
+// search.
+func (r *IndexReader) resolveSuffixOffset(pos int64) (suffixOffsetEntry, bool) {
+	offsets := r.SuffixOffsets
+	i := sort.Search(len(offsets), func(i int) bool { return offsets[i].Start > pos }) - 1
+	if i < 0 || i >= len(offsets) {
+		return suffixOffsetEntry{}, false
+	}
+	e := offsets[i]
+	if pos < e.Start || pos >= e.Start+e.Length {
+		return suffixOffsetEntry{}, false
+	}
+	return e, true
+}
+
+// SubstringSearch matches an arbitrary substring or regex pattern against
+// the suffix array built over every indexed document's raw bytes. Unlike
+// Search, this can find things the tokenizer would never emit as a term:
+// punctuation, partial identifiers ("HTTPHand"), log fragments ("code=5").
+func SubstringSearch(idx *IndexReader, pattern string) ([]SearchResult, error) {
+	if idx.Suffix == nil {
+		return nil, fmt.Errorf("no suffix index available (re-run `devscope index` to build one)")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	matches := idx.Suffix.FindAllIndex(re, -1)
+
+	perDoc := make(map[uint32][]int64) // docID -> match offsets within the file
+	for _, m := range matches {
+		start, end := int64(m[0]), int64(m[1])
+		entry, ok := idx.resolveSuffixOffset(start)
+		if !ok {
+			continue
+		}
+		// A match that runs past this document's bytes has crossed the
+		// delimiter (see indexer.suffixDocDelimiter) into whatever follows
+		// it in the buffer - that text never actually appears together in
+		// any single file, so it's not a real hit.
+		if end > entry.Start+entry.Length {
+			continue
+		}
+		perDoc[entry.DocID] = append(perDoc[entry.DocID], start-entry.Start)
+	}
+
+	var results []SearchResult
+	for docID, offs := range perDoc {
+		doc, ok := idx.Docs[docID]
+		if !ok {
+			continue
+		}
+		snippet, lineNum := getSnippetAtOffset(doc.Path, offs[0])
+		results = append(results, SearchResult{
+			DocID:      docID,
+			Path:       doc.Path,
+			Score:      float64(len(offs)),
+			Snippet:    snippet,
+			LineNum:    lineNum,
+			MatchCount: uint32(len(offs)),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > 10 {
+		results = results[:10]
+	}
+	return results, nil
+}
+
+// getSnippetAtOffset reads the line containing a raw byte offset into path.
+func getSnippetAtOffset(path string, offset int64) (string, uint32) {
+	data, err := os.ReadFile(path)
+	if err != nil || offset < 0 || offset > int64(len(data)) {
+		return "", 0
+	}
+
+	lineNum := uint32(1)
+	lineStart := int64(0)
+	for i := int64(0); i < offset; i++ {
+		if data[i] == '\n' {
+			lineNum++
+			lineStart = i + 1
+		}
+	}
+
+	lineEnd := lineStart
+	for lineEnd < int64(len(data)) && data[lineEnd] != '\n' {
+		lineEnd++
+	}
+
+	line := string(data[lineStart:lineEnd])
+	if len(line) > 200 {
+		line = line[:200] + "..."
+	}
+	return strings.TrimSpace(line), lineNum
+}
@@ -0,0 +1,356 @@
+package query
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// loadTrigramIndex reads trigram.bin: every trigram seen in the corpus
+// mapped to its sorted, delta+varint-decoded DocID list. Optional, like the
+// suffix index - an index built before this feature existed just won't
+// have one, and RegexSearch falls back to scanning every document.
+func loadTrigramIndex(path string) (map[string][]uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header) != "DEVSCOPE_TRI" {
+		return nil, fmt.Errorf("bad trigram header")
+	}
+	if _, err := r.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+
+	trigrams := make(map[string][]uint32, count)
+	triBuf := make([]byte, 3)
+	for i := uint32(0); i < count; i++ {
+		if _, err := io.ReadFull(r, triBuf); err != nil {
+			return nil, err
+		}
+
+		var docCount uint32
+		if err := binary.Read(r, binary.LittleEndian, &docCount); err != nil {
+			return nil, err
+		}
+
+		docIDs := make([]uint32, docCount)
+		prev := uint32(0)
+		for j := range docIDs {
+			delta, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			prev += uint32(delta)
+			docIDs[j] = prev
+		}
+
+		trigrams[string(triBuf)] = docIDs
+	}
+
+	return trigrams, nil
+}
+
+// trigramOp is a node kind in the boolean query planned from a regex's
+// syntax tree: opAll (no constraint - every doc is a candidate), opTri (a
+// literal 3-byte substring that must appear somewhere in any matching
+// doc), or opAnd/opOr over sub-nodes.
+type trigramOp int
+
+const (
+	opAll trigramOp = iota
+	opTri
+	opAnd
+	opOr
+)
+
+type trigramQuery struct {
+	op   trigramOp
+	tri  string
+	subs []*trigramQuery
+}
+
+// planTrigramQuery walks a parsed regex's syntax tree and derives a
+// conservative boolean expression over trigrams that must be present for
+// the pattern to match: concatenation ANDs the trigrams of its parts
+// together, alternation ORs its branches, and anything that can match zero
+// or more times (*, ?, char classes, anchors, ...) can't guarantee a
+// trigram is present so it falls back to opAll - which only costs
+// candidate-filtering precision, never correctness, since the real
+// regexp.Regexp still does the actual matching afterward.
+func planTrigramQuery(re *syntax.Regexp) *trigramQuery {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return trigramsFromLiteral(string(re.Rune))
+
+	case syntax.OpConcat:
+		return planConcat(re.Sub)
+
+	case syntax.OpAlternate:
+		sub := make([]*trigramQuery, 0, len(re.Sub))
+		for _, s := range re.Sub {
+			q := planTrigramQuery(s)
+			if q.op == opAll {
+				// One unconstrained branch means the whole alternation is
+				// unconstrained: any doc could satisfy it via that branch.
+				return &trigramQuery{op: opAll}
+			}
+			sub = append(sub, q)
+		}
+		return &trigramQuery{op: opOr, subs: sub}
+
+	case syntax.OpCapture:
+		return planTrigramQuery(re.Sub[0])
+
+	case syntax.OpPlus:
+		// x+ matches the operand at least once, so whatever trigrams are
+		// required within it are still required - but not any spanning the
+		// boundary to a repeat, so just recurse into the single occurrence.
+		return planTrigramQuery(re.Sub[0])
+
+	default:
+		// OpStar, OpQuest, OpRepeat(min 0), OpCharClass, OpAnyChar,
+		// anchors, etc. - none of these guarantee a literal trigram is
+		// present, so don't constrain the candidate set.
+		return &trigramQuery{op: opAll}
+	}
+}
+
+// planConcat merges adjacent literal nodes in a concatenation before
+// extracting trigrams, so e.g. literal nodes "Handl" + "eError" still
+// yield the trigrams spanning "dle". A literal inside a capture group is
+// planned on its own, so no trigram spanning that group's boundary is
+// required.
+func planConcat(subs []*syntax.Regexp) *trigramQuery {
+	var lit strings.Builder
+	and := &trigramQuery{op: opAnd}
+
+	flush := func() {
+		if lit.Len() == 0 {
+			return
+		}
+		q := trigramsFromLiteral(lit.String())
+		if q.op != opAll {
+			and.subs = append(and.subs, q)
+		}
+		lit.Reset()
+	}
+
+	for _, s := range subs {
+		if s.Op == syntax.OpLiteral {
+			lit.WriteString(string(s.Rune))
+			continue
+		}
+		flush()
+		and.subs = append(and.subs, planTrigramQuery(s))
+	}
+	flush()
+
+	// Drop opAll members - they don't constrain anything.
+	filtered := and.subs[:0]
+	for _, s := range and.subs {
+		if s.op != opAll {
+			filtered = append(filtered, s)
+		}
+	}
+	and.subs = filtered
+
+	if len(and.subs) == 0 {
+		return &trigramQuery{op: opAll}
+	}
+	if len(and.subs) == 1 {
+		return and.subs[0]
+	}
+	return and
+}
+
+// trigramsFromLiteral extracts every distinct 3-byte substring of a literal
+// run and ANDs them together. A run shorter than 3 bytes can't constrain
+// anything.
+func trigramsFromLiteral(s string) *trigramQuery {
+	if len(s) < 3 {
+		return &trigramQuery{op: opAll}
+	}
+
+	seen := make(map[string]bool)
+	var subs []*trigramQuery
+	for i := 0; i+3 <= len(s); i++ {
+		tri := s[i : i+3]
+		if seen[tri] {
+			continue
+		}
+		seen[tri] = true
+		subs = append(subs, &trigramQuery{op: opTri, tri: tri})
+	}
+	if len(subs) == 1 {
+		return subs[0]
+	}
+	return &trigramQuery{op: opAnd, subs: subs}
+}
+
+// candidateDocs evaluates a trigram query against idx's trigram postings,
+// returning the set of docs that could satisfy it and whether that set is
+// actually constrained (false means "every doc is a candidate" - RegexSearch
+// falls back to scanning everything in that case).
+func (idx *IndexReader) candidateDocs(q *trigramQuery) (map[uint32]bool, bool) {
+	switch q.op {
+	case opAll:
+		return nil, false
+
+	case opTri:
+		set := make(map[uint32]bool)
+		for _, id := range idx.Trigrams[q.tri] {
+			set[id] = true
+		}
+		return set, true
+
+	case opAnd:
+		var result map[uint32]bool
+		constrained := false
+		for _, sub := range q.subs {
+			docs, ok := idx.candidateDocs(sub)
+			if !ok {
+				continue
+			}
+			if !constrained {
+				result = docs
+				constrained = true
+				continue
+			}
+			result = intersectDocSets(result, docs)
+		}
+		return result, constrained
+
+	case opOr:
+		result := make(map[uint32]bool)
+		for _, sub := range q.subs {
+			docs, ok := idx.candidateDocs(sub)
+			if !ok {
+				// Any unconstrained branch makes the whole OR unconstrained.
+				return nil, false
+			}
+			for id := range docs {
+				result[id] = true
+			}
+		}
+		return result, true
+
+	default:
+		return nil, false
+	}
+}
+
+func intersectDocSets(a, b map[uint32]bool) map[uint32]bool {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	out := make(map[uint32]bool)
+	for id := range a {
+		if b[id] {
+			out[id] = true
+		}
+	}
+	return out
+}
+
+// RegexSearch matches pattern against every indexed document's raw
+// content. When idx has a trigram index, the regex's syntax tree is turned
+// into a boolean expression over required trigrams (planTrigramQuery) and
+// evaluated against the trigram postings first, so regexp.Regexp only ever
+// runs against documents that could possibly match instead of the whole
+// corpus.
+func RegexSearch(idx *IndexReader, pattern string) ([]SearchResult, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	var candidates map[uint32]bool
+	constrained := false
+	if idx.Trigrams != nil {
+		parsed, err := syntax.Parse(pattern, syntax.Perl)
+		if err == nil {
+			q := planTrigramQuery(parsed.Simplify())
+			candidates, constrained = idx.candidateDocs(q)
+		}
+	}
+
+	var results []SearchResult
+	for docID, doc := range idx.Docs {
+		if constrained && !candidates[docID] {
+			continue
+		}
+
+		matches, err := grepFile(doc.Path, re)
+		if err != nil || len(matches) == 0 {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			DocID:      docID,
+			Path:       doc.Path,
+			Score:      float64(len(matches)),
+			Snippet:    matches[0].text,
+			LineNum:    matches[0].line,
+			MatchCount: uint32(len(matches)),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > 10 {
+		results = results[:10]
+	}
+	return results, nil
+}
+
+type regexMatch struct {
+	line uint32
+	text string
+}
+
+// grepFile runs re line-by-line against path, mirroring getSnippet's
+// reading style.
+func grepFile(path string, re *regexp.Regexp) ([]regexMatch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var matches []regexMatch
+	scanner := bufio.NewScanner(f)
+	lineNum := uint32(1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if re.MatchString(line) {
+			snippet := line
+			if len(snippet) > 200 {
+				snippet = snippet[:200] + "..."
+			}
+			matches = append(matches, regexMatch{line: lineNum, text: strings.TrimSpace(snippet)})
+		}
+		lineNum++
+	}
+	return matches, scanner.Err()
+}
@@ -0,0 +1,80 @@
+package query
+
+import (
+	"regexp/syntax"
+	"testing"
+)
+
+func planFromPattern(t *testing.T, pattern string) *trigramQuery {
+	t.Helper()
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		t.Fatalf("syntax.Parse(%q): %v", pattern, err)
+	}
+	return planTrigramQuery(parsed.Simplify())
+}
+
+// TestCandidateDocsLiteralNarrowsToMatchingDocs covers chunk1-1's trigram
+// candidate planning: a literal long enough to yield at least one trigram
+// must narrow RegexSearch's candidate set to exactly the docs that
+// contain it, not fall back to "every doc is a candidate".
+func TestCandidateDocsLiteralNarrowsToMatchingDocs(t *testing.T) {
+	idx := &IndexReader{
+		Trigrams: map[string][]uint32{
+			"abc": {1, 3},
+			"bcd": {1, 2, 3},
+		},
+	}
+
+	q := planFromPattern(t, "abcd")
+	docs, constrained := idx.candidateDocs(q)
+	if !constrained {
+		t.Fatal("expected a 4-byte literal to constrain the candidate set")
+	}
+	want := map[uint32]bool{1: true, 3: true}
+	if len(docs) != len(want) {
+		t.Fatalf("got %v, want %v", docs, want)
+	}
+	for id := range want {
+		if !docs[id] {
+			t.Fatalf("expected doc %d among candidates, got %v", id, docs)
+		}
+	}
+}
+
+// TestCandidateDocsShortPatternIsUnconstrained covers the correctness-over-
+// precision fallback: a pattern too short to guarantee any 3-byte literal
+// (e.g. a single char class) must report unconstrained rather than silently
+// narrow out a doc that could still match.
+func TestCandidateDocsShortPatternIsUnconstrained(t *testing.T) {
+	idx := &IndexReader{
+		Trigrams: map[string][]uint32{"abc": {1}},
+	}
+
+	q := planFromPattern(t, "a.")
+	_, constrained := idx.candidateDocs(q)
+	if constrained {
+		t.Fatal("expected a pattern with no guaranteed 3-byte literal to be unconstrained")
+	}
+}
+
+// TestCandidateDocsAlternationUnionsBranches covers opOr: each branch's
+// candidates must be unioned, not intersected, since a doc only needs to
+// satisfy one alternative.
+func TestCandidateDocsAlternationUnionsBranches(t *testing.T) {
+	idx := &IndexReader{
+		Trigrams: map[string][]uint32{
+			"foo": {1},
+			"bar": {2},
+		},
+	}
+
+	q := planFromPattern(t, "foo|bar")
+	docs, constrained := idx.candidateDocs(q)
+	if !constrained {
+		t.Fatal("expected an alternation of two literals to constrain the candidate set")
+	}
+	if !docs[1] || !docs[2] || len(docs) != 2 {
+		t.Fatalf("got %v, want docs 1 and 2", docs)
+	}
+}
@@ -6,18 +6,27 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 )
 
 const (
 	DocsHeader  = "DEVSCOPE_DOCS"
-	DocsVersion = 1
+	DocsVersion = 5
+
+	// docsHeaderLen is the size in bytes of the fixed header written before
+	// any records: DocsHeader + version byte + NextDocID (4) + AvgDocLen (8).
+	docsHeaderLen = len(DocsHeader) + 1 + 4 + 8
 )
 
 // DocWriter handles writing to docs.bin
 type DocWriter struct {
-	file *os.File
-	w    *bufio.Writer
+	file    *os.File
+	w       *bufio.Writer
+	maxSeen uint32
+
+	totalTokens uint64
+	numDocs     uint32
 }
 
 func NewDocWriter(path string) (*DocWriter, error) {
@@ -26,7 +35,7 @@ func NewDocWriter(path string) (*DocWriter, error) {
 		return nil, err
 	}
 	dw := &DocWriter{file: f, w: bufio.NewWriter(f)}
-	
+
 	// Write Header
 	if _, err := dw.w.WriteString(DocsHeader); err != nil {
 		return nil, err
@@ -34,7 +43,12 @@ func NewDocWriter(path string) (*DocWriter, error) {
 	if err := dw.w.WriteByte(DocsVersion); err != nil {
 		return nil, err
 	}
-	
+	// Reserve space for NextDocID (4) and AvgDocLen (8); both get patched
+	// in on Close once we know every record that will be written.
+	if _, err := dw.w.Write(make([]byte, 4+8)); err != nil {
+		return nil, err
+	}
+
 	return dw, nil
 }
 
@@ -47,8 +61,15 @@ func (w *DocWriter) Write(rec models.DocumentRecord) error {
 	//   Path (PathLen)
 	//   TimestampMin (8)
 	//   TimestampMax (8)
+	//   Size (8)
+	//   Mtime (8)
+	//   TokenCount (4)
+	//   LanguageLen (2)
+	//   Language (LanguageLen)
+	//   ContentHashLen (2)
+	//   ContentHash (ContentHashLen)
 
-	buf := make([]byte, 4+1+2+len(rec.Path)+8+8)
+	buf := make([]byte, 4+1+2+len(rec.Path)+8+8+8+8+4+2+len(rec.Language)+2+len(rec.ContentHash))
 	offset := 0
 
 	binary.LittleEndian.PutUint32(buf[offset:], rec.DocID)
@@ -69,14 +90,65 @@ func (w *DocWriter) Write(rec models.DocumentRecord) error {
 	binary.LittleEndian.PutUint64(buf[offset:], uint64(rec.TimestampMax))
 	offset += 8
 
+	binary.LittleEndian.PutUint64(buf[offset:], uint64(rec.Size))
+	offset += 8
+
+	binary.LittleEndian.PutUint64(buf[offset:], uint64(rec.Mtime))
+	offset += 8
+
+	binary.LittleEndian.PutUint32(buf[offset:], rec.TokenCount)
+	offset += 4
+
+	binary.LittleEndian.PutUint16(buf[offset:], uint16(len(rec.Language)))
+	offset += 2
+
+	copy(buf[offset:], rec.Language)
+	offset += len(rec.Language)
+
+	binary.LittleEndian.PutUint16(buf[offset:], uint16(len(rec.ContentHash)))
+	offset += 2
+
+	copy(buf[offset:], rec.ContentHash)
+	offset += len(rec.ContentHash)
+
+	if rec.DocID > w.maxSeen {
+		w.maxSeen = rec.DocID
+	}
+	w.totalTokens += uint64(rec.TokenCount)
+	w.numDocs++
+
 	_, err := w.w.Write(buf)
 	return err
 }
 
+// Close flushes all buffered records and patches in the NextDocID and
+// AvgDocLen header fields so the next Build() can keep handing out fresh
+// DocIDs and so BM25Scorer has a corpus-wide length to normalize against.
 func (w *DocWriter) Close() error {
+	return w.CloseWithNextDocID(w.maxSeen + 1)
+}
+
+// CloseWithNextDocID is like Close but lets the caller pick the persisted
+// DocID counter explicitly, e.g. to preserve a counter that is already
+// higher than any DocID written in this run (files that were deleted don't
+// free up their old IDs for reuse).
+func (w *DocWriter) CloseWithNextDocID(nextDocID uint32) error {
 	if err := w.w.Flush(); err != nil {
 		return err
 	}
+
+	var avgDocLen float64
+	if w.numDocs > 0 {
+		avgDocLen = float64(w.totalTokens) / float64(w.numDocs)
+	}
+
+	hdr := make([]byte, 4+8)
+	binary.LittleEndian.PutUint32(hdr[0:4], nextDocID)
+	binary.LittleEndian.PutUint64(hdr[4:12], math.Float64bits(avgDocLen))
+	if _, err := w.file.WriteAt(hdr, int64(len(DocsHeader)+1)); err != nil {
+		return err
+	}
+
 	return w.file.Close()
 }
 
@@ -84,6 +156,15 @@ func (w *DocWriter) Close() error {
 type DocReader struct {
 	file *os.File
 	r    *bufio.Reader
+
+	// NextDocID is the persisted monotonic DocID counter, so a subsequent
+	// Build() can assign fresh IDs to new files without colliding with IDs
+	// that belonged to since-deleted files.
+	NextDocID uint32
+
+	// AvgDocLen is the corpus-wide average TokenCount at last index time,
+	// used by BM25Scorer for length normalization.
+	AvgDocLen float64
 }
 
 func NewDocReader(path string) (*DocReader, error) {
@@ -108,6 +189,14 @@ func NewDocReader(path string) (*DocReader, error) {
 	if version != DocsVersion {
 		return nil, fmt.Errorf("unsupported version: %d", version)
 	}
+	if err := binary.Read(dr.r, binary.LittleEndian, &dr.NextDocID); err != nil {
+		return nil, fmt.Errorf("reading next-docid counter: %w", err)
+	}
+	var avgDocLenBits uint64
+	if err := binary.Read(dr.r, binary.LittleEndian, &avgDocLenBits); err != nil {
+		return nil, fmt.Errorf("reading avg-doc-len header: %w", err)
+	}
+	dr.AvgDocLen = math.Float64frombits(avgDocLenBits)
 
 	return dr, nil
 }
@@ -153,6 +242,43 @@ func (r *DocReader) ReadNext() (models.DocumentRecord, error) {
 		return rec, err
 	}
 
+	// Size
+	if err := binary.Read(r.r, binary.LittleEndian, &rec.Size); err != nil {
+		return rec, err
+	}
+
+	// Mtime
+	if err := binary.Read(r.r, binary.LittleEndian, &rec.Mtime); err != nil {
+		return rec, err
+	}
+
+	// TokenCount
+	if err := binary.Read(r.r, binary.LittleEndian, &rec.TokenCount); err != nil {
+		return rec, err
+	}
+
+	// LanguageLen + Language
+	var langLen uint16
+	if err := binary.Read(r.r, binary.LittleEndian, &langLen); err != nil {
+		return rec, err
+	}
+	langBuf := make([]byte, langLen)
+	if _, err := io.ReadFull(r.r, langBuf); err != nil {
+		return rec, err
+	}
+	rec.Language = string(langBuf)
+
+	// ContentHashLen + ContentHash
+	var hashLen uint16
+	if err := binary.Read(r.r, binary.LittleEndian, &hashLen); err != nil {
+		return rec, err
+	}
+	hashBuf := make([]byte, hashLen)
+	if _, err := io.ReadFull(r.r, hashBuf); err != nil {
+		return rec, err
+	}
+	rec.ContentHash = string(hashBuf)
+
 	return rec, nil
 }
 
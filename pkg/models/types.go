@@ -15,6 +15,24 @@ type DocumentRecord struct {
 	Path         string
 	TimestampMin int64 // For logs: epoch start. For code: ModTime.
 	TimestampMax int64 // For logs: epoch end. For code: 0 or ModTime.
+
+	// Fingerprint fields used by incremental indexing to decide whether a
+	// file needs to be re-tokenized on the next Build(). ContentHash is
+	// also the key under which the file's token stream is cached in
+	// outDir/objects - see indexer.contentHash/indexer.GC.
+	Size        int64  // file size in bytes at last index time
+	Mtime       int64  // file mtime (unix nanos) at last index time
+	ContentHash string // hex SHA-256 of the file bytes + detected language at last index time
+
+	// TokenCount is the document's length in tokens (|d| in BM25), used
+	// alongside docs.bin's persisted AvgDocLen header field to compute the
+	// length-normalization term. See query.BM25Scorer.
+	TokenCount uint32
+
+	// Language is the detected source language (e.g. "Go", "Python"), used
+	// to pick a per-language tokenizer at index time and printed alongside
+	// search results. Empty for logs and languages go-enry doesn't detect.
+	Language string
 }
 
 // Posting represents a single hit in the index.
@@ -29,12 +47,17 @@ type Posting struct {
 type LexiconEntry struct {
 	Term         string
 	DocFreq      uint32
-	Offset       uint64 // Offset in index.bin
+	Offset       uint64 // Offset of the posting list within its segment's .idx file
 	PostingCount uint32
 }
 
 const (
 	DocsFileName    = "docs.bin"
-	IndexFileName   = "index.bin"
-	LexiconFileName = "lexicon.bin"
+	SuffixFileName  = "suffix.bin"
+	TrigramFileName = "trigram.bin"
+
+	// SegmentManifestName lists the live on-disk segments (oldest first),
+	// one segment number per line. Segment N's postings live in
+	// seg-00000N.idx/.lex - see indexer/segments.go and query/segments.go.
+	SegmentManifestName = "segments.manifest"
 )